@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Клиент служебных ручек /admin/ запущенного сервиса.
+//
+//	go run ./cmd/adminctl -addr http://localhost:8081 ingest-status
+//	go run ./cmd/adminctl -email a@b.co -reason "ticket 42" subject-erase
+var commands = map[string]struct {
+	method, path, help string
+}{
+	"ingest-status":  {http.MethodGet, "/admin/ingest/status", "partitions, offsets, lag, rate and last error of the consumer"},
+	"ingest-pause":   {http.MethodPost, "/admin/ingest/pause", "stop consuming without leaving the consumer group"},
+	"ingest-resume":  {http.MethodPost, "/admin/ingest/resume", "resume consuming after ingest-pause"},
+	"subject-export": {http.MethodPost, "/admin/subjects/export", "all orders of -customer-id / -email"},
+	"subject-erase":  {http.MethodPost, "/admin/subjects/erase", "anonymize all orders of -customer-id / -email"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: adminctl [flags] <command>\n\ncommands:\n")
+	for name, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, c.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	addr := flag.String("addr", envOr("ADMIN_URL", "http://localhost:8081"), "service base URL")
+	apiKey := flag.String("api-key", os.Getenv("API_KEY"), "API key with the admin role (X-API-Key)")
+	customerID := flag.String("customer-id", "", "subject-*: customer_id of the data subject")
+	email := flag.String("email", "", "subject-*: delivery email of the data subject")
+	reason := flag.String("reason", "", "subject-erase: reason recorded in the erasure log")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	var payload io.Reader
+	if strings.HasPrefix(cmd.path, "/admin/subjects/") {
+		data, err := json.Marshal(map[string]string{"customer_id": *customerID, "email": *email, "reason": *reason})
+		if err != nil {
+			log.Fatalf("encode: %v", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(cmd.method, strings.TrimRight(*addr, "/")+cmd.path, payload)
+	if err != nil {
+		log.Fatalf("request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if *apiKey != "" {
+		req.Header.Set("X-API-Key", *apiKey)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("read: %v", err)
+	}
+
+	var out bytes.Buffer
+	if json.Indent(&out, body, "", "  ") != nil {
+		out.Reset()
+		out.Write(body)
+	}
+	fmt.Println(strings.TrimSpace(out.String()))
+	if resp.StatusCode >= 300 {
+		os.Exit(1)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
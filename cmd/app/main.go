@@ -2,15 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
+	"github.com/oziev02/wb/internal/adapters/grpcapi"
 	"github.com/oziev02/wb/internal/adapters/httpapi"
-	"github.com/oziev02/wb/internal/adapters/mq/kafka"
+	"github.com/oziev02/wb/internal/adapters/mq"
 	"github.com/oziev02/wb/internal/app"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/ratelimit"
+	"github.com/oziev02/wb/internal/redact"
 )
 
 func main() {
@@ -26,17 +35,89 @@ func main() {
 	if err != nil {
 		log.Fatalf("container: %v", err)
 	}
+	defer c.Close()
+
+	src, err := app.NewMessageSource(ctx, cfg)
+	if err != nil {
+		log.Fatalf("message source: %v", err)
+	}
+	ingestor := mq.NewIngestor(src, c.Codecs, c.Svc)
+	if c.Breaker != nil {
+		app.PauseOnBreaker(c.Breaker, ingestor, cfg.BreakerOpenTimeout)
+	}
+
+	authn, err := auth.New(cfg.AuthConfig())
+	if err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	if !authn.Enabled() {
+		log.Println("WARNING: AUTH_ENABLED=false, HTTP and gRPC APIs are open and every caller is treated as admin")
+	}
 
 	mux := http.NewServeMux()
-	h := httpapi.NewHandler(c.Svc)
+	policy, err := redact.Parse(cfg.PIIPolicy)
+	if err != nil {
+		log.Fatalf("pii policy: %v", err)
+	}
+	log.Printf("PII policy: %s", policy)
+	h := httpapi.NewHandler(c.Svc, httpapi.WithRedaction(policy))
 	h.Routes(mux)
+	httpapi.NewAdminHandler(ingestor, c.Svc, authn).Routes(mux)
+	httpapi.NewReadiness(
+		// недоступная БД сама по себе не выводит из ротации: чтения обслуживает кэш,
+		// в том числе устаревшими записями (stale-while-error)
+		httpapi.HealthCheck{Name: "db", Check: func(ctx context.Context) (string, error) {
+			return "up", c.Ping(ctx)
+		}},
+		httpapi.HealthCheck{Name: "cache", Critical: true, Check: func(ctx context.Context) (string, error) {
+			if n := c.Cache.Len(); n > 0 {
+				return fmt.Sprintf("%d orders", n), nil
+			}
+			if err := c.Ping(ctx); err != nil {
+				return "", errors.New("cache is empty and db is unreachable")
+			}
+			return "empty", nil
+		}},
+		httpapi.HealthCheck{Name: "db_breaker", Check: func(context.Context) (string, error) {
+			if c.Breaker == nil {
+				return "disabled", nil
+			}
+			return c.Breaker.State().String(), nil
+		}},
+		httpapi.HealthCheck{Name: "ingest", Check: func(context.Context) (string, error) {
+			if paused, reasons := ingestor.Paused(); paused {
+				return "paused: " + strings.Join(reasons, ","), nil
+			}
+			return "running", nil
+		}},
+	).Routes(mux)
+	mux.Handle("/metrics", metrics.Handler())
+	httpapi.ServeOpenAPI(mux)
 	httpapi.ServeStatic(mux, "./web")
 
-	srv := &http.Server{Addr: cfg.HTTPAddr, Handler: mux}
-
-	consumer := kafka.NewConsumer(kafka.Config{
-		Brokers: cfg.KafkaBrokers, Topic: cfg.KafkaTopic, GroupID: cfg.KafkaGroup,
-	}, c.Svc)
+	grpcSrv, grpcHealth := grpcapi.NewServer(c.Svc, authn, policy)
+	if c.Breaker != nil {
+		app.HealthOnBreaker(c.Breaker, grpcHealth, grpcapi.ServiceName)
+	}
+	// Auth до ValidateRequests: неаутентифицированный клиент не узнаёт подробностей валидации.
+	// RateLimit после Auth: корзина выбирается по API-ключу, а не только по IP.
+	// AuthFailures перед Auth: отклонённые Auth запросы до RateLimit не доходят.
+	var handler http.Handler = httpapi.ValidateRequests(mux)
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimitEnabled {
+		rl, err := cfg.RateLimitConfig()
+		if err != nil {
+			log.Fatalf("rate limit: %v", err)
+		}
+		log.Printf("rate limits: %s", rl)
+		limiter = ratelimit.New(rl)
+		handler = httpapi.RateLimit(limiter, cfg.RateLimitTrustXFF, handler)
+	}
+	handler = httpapi.Auth(authn, handler)
+	if limiter != nil {
+		handler = httpapi.AuthFailures(limiter, cfg.RateLimitTrustXFF, handler)
+	}
+	srv := &http.Server{Addr: cfg.HTTPAddr, Handler: httpapi.RequestID(httpapi.Compress(handler))}
 
 	go func() {
 		log.Printf("HTTP listening on %s", cfg.HTTPAddr)
@@ -46,12 +127,58 @@ func main() {
 	}()
 
 	go func() {
-		if err := consumer.Run(ctx); err != nil {
-			log.Printf("kafka stopped: %v", err)
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		log.Printf("gRPC listening on %s", cfg.GRPCAddr)
+		if err := grpcSrv.Serve(lis); err != nil {
+			log.Fatalf("grpc: %v", err)
+		}
+	}()
+
+	go func() {
+		if err := ingestor.Run(ctx); err != nil {
+			log.Printf("%s ingest stopped: %v", cfg.MQSource, err)
 			stop()
 		}
 	}()
 
+	if cfg.RetentionDays > 0 {
+		log.Printf("retention: orders older than %d days go to %s", cfg.RetentionDays, cfg.RetentionMode)
+	}
+	for _, sh := range c.Shards {
+		if sh.Relay != nil {
+			go func() {
+				if err := sh.Relay.Run(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("outbox relay %s stopped: %v", sh.Name, err)
+				}
+			}()
+		}
+
+		if sh.Reencryptor != nil {
+			go func() {
+				if err := sh.Reencryptor.Run(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("pii reencryptor %s stopped: %v", sh.Name, err)
+				}
+			}()
+		}
+
+		go func() {
+			if err := sh.Partitions.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("partition maintenance %s stopped: %v", sh.Name, err)
+			}
+		}()
+
+		if sh.Retention != nil {
+			go func() {
+				if err := sh.Retention.Run(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("retention %s stopped: %v", sh.Name, err)
+				}
+			}()
+		}
+	}
+
 	<-ctx.Done()
 	log.Println("shutting down...")
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -59,4 +186,12 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("http shutdown: %v", err)
 	}
+	grpcHealth.Shutdown()
+	stopped := make(chan struct{})
+	go func() { grpcSrv.GracefulStop(); close(stopped) }()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcSrv.Stop() // WatchOrders держит стримы бесконечно
+	}
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/brianvoe/gofakeit/v7"
 	"log"
 	"math/rand"
@@ -13,6 +12,8 @@ import (
 
 	kafkago "github.com/segmentio/kafka-go"
 
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/adapters/mq/kafka"
 	"github.com/oziev02/wb/internal/domain"
 )
 
@@ -32,10 +33,52 @@ func envInt(key string, def int) int {
 	return def
 }
 
+func envBool(key string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(key))
+	return b
+}
+
 func brokersEnv() []string {
 	return strings.Split(env("KAFKA_BROKERS", "localhost:9092"), ",")
 }
 
+// те же переменные, что и у cmd/app (см. app.Config).
+func securityEnv() kafka.Security {
+	return kafka.Security{
+		TLS:           envBool("KAFKA_TLS_ENABLED"),
+		CAFile:        os.Getenv("KAFKA_TLS_CA_FILE"),
+		CertFile:      os.Getenv("KAFKA_TLS_CERT_FILE"),
+		KeyFile:       os.Getenv("KAFKA_TLS_KEY_FILE"),
+		SkipVerify:    envBool("KAFKA_TLS_INSECURE_SKIP_VERIFY"),
+		SASLMechanism: os.Getenv("KAFKA_SASL_MECHANISM"),
+		Username:      os.Getenv("KAFKA_SASL_USERNAME"),
+		Password:      os.Getenv("KAFKA_SASL_PASSWORD"),
+	}
+}
+
+// PRODUCE_FORMAT: json | protobuf | avro.
+func codecsEnv() (*codec.Registry, string) {
+	formats := map[string]string{
+		"json":     codec.ContentTypeJSON,
+		"protobuf": codec.ContentTypeProtobuf,
+		"avro":     codec.ContentTypeAvro,
+	}
+	format := env("PRODUCE_FORMAT", "json")
+	ct, ok := formats[format]
+	if !ok {
+		log.Fatalf("unknown PRODUCE_FORMAT %q", format)
+	}
+	codecs := []codec.Codec{codec.Protobuf{}}
+	if ct == codec.ContentTypeAvro {
+		reg, err := codec.NewFileSchemaRegistry(env("AVRO_SCHEMA_DIR", "./schemas/avro/order"))
+		if err != nil {
+			log.Fatalf("avro schemas: %v", err)
+		}
+		codecs = append(codecs, codec.NewAvro(reg))
+	}
+	return codec.NewRegistry(codec.JSON{}, codecs...), ct
+}
+
 func fakeOrder() domain.Order {
 	gofakeit.Seed(time.Now().UnixNano())
 	uid := gofakeit.UUID()
@@ -62,9 +105,10 @@ func fakeOrder() domain.Order {
 	}
 
 	return domain.Order{
-		OrderUID:    uid,
-		TrackNumber: gofakeit.LetterN(12),
-		Entry:       "WBIL",
+		SchemaVersion: domain.CurrentSchemaVersion,
+		OrderUID:      uid,
+		TrackNumber:   gofakeit.LetterN(12),
+		Entry:         "WBIL",
 		Delivery: domain.Delivery{
 			Name:    gofakeit.Name(),
 			Phone:   gofakeit.Phone(),
@@ -99,11 +143,17 @@ func fakeOrder() domain.Order {
 func main() {
 	topic := env("KAFKA_TOPIC", "orders")
 	n := envInt("PRODUCE_N", 100)
+	codecs, contentType := codecsEnv()
 
+	transport, err := kafka.NewTransport(securityEnv())
+	if err != nil {
+		log.Fatalf("kafka transport: %v", err)
+	}
 	w := &kafkago.Writer{
-		Addr:     kafkago.TCP(brokersEnv()...),
-		Topic:    topic,
-		Balancer: &kafkago.LeastBytes{},
+		Addr:      kafkago.TCP(brokersEnv()...),
+		Topic:     topic,
+		Balancer:  &kafkago.LeastBytes{},
+		Transport: transport,
 	}
 	defer func() {
 		if err := w.Close(); err != nil {
@@ -114,14 +164,18 @@ func main() {
 	ctx := context.Background()
 	for i := 0; i < n; i++ {
 		o := fakeOrder()
-		b, err := json.Marshal(o)
+		b, headers, err := codecs.Encode(contentType, o)
 		if err != nil {
-			log.Fatalf("marshal: %v", err)
+			log.Fatalf("encode: %v", err)
+		}
+		msg := kafkago.Message{Key: []byte(o.OrderUID), Value: b}
+		for k, v := range headers {
+			msg.Headers = append(msg.Headers, kafkago.Header{Key: k, Value: []byte(v)})
 		}
-		if err := w.WriteMessages(ctx, kafkago.Message{Value: b}); err != nil {
+		if err := w.WriteMessages(ctx, msg); err != nil {
 			log.Fatalf("write: %v", err)
 		}
 		time.Sleep(time.Duration(rand.Intn(300)) * time.Millisecond)
 	}
-	log.Printf("produced %d messages to %s", n, topic)
+	log.Printf("produced %d %s messages to %s", n, contentType, topic)
 }
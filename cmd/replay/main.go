@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/oziev02/wb/internal/adapters/mq/kafka"
+	"github.com/oziev02/wb/internal/app"
+)
+
+// Перечитывает диапазон партиции Kafka и прогоняет заказы через OrderService.
+// По умолчанию dry-run: только считает, что было бы принято/отклонено/не изменилось.
+//
+//	go run ./cmd/replay -partition 0 -from-time 2025-01-01T00:00:00Z -apply
+func main() {
+	var (
+		topic      = flag.String("topic", "", "topic (default: KAFKA_TOPIC)")
+		partition  = flag.Int("partition", 0, "partition to replay")
+		fromOffset = flag.Int64("from-offset", -1, "first offset (default: earliest or -from-time)")
+		toOffset   = flag.Int64("to-offset", -1, "last offset, inclusive (default: current end or -to-time)")
+		fromTime   = flag.String("from-time", "", "start timestamp, RFC3339")
+		toTime     = flag.String("to-time", "", "end timestamp, RFC3339, exclusive")
+		apply      = flag.Bool("apply", false, "write changes (default: dry-run)")
+		idle       = flag.Duration("idle", 0, "stop after waiting this long for the next message (default 10s)")
+	)
+	flag.Parse()
+
+	cfg, err := app.LoadConfig()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if *topic == "" {
+		*topic = cfg.KafkaTopic
+	}
+	rc := kafka.ReplayConfig{
+		Brokers:    cfg.KafkaBrokers,
+		Topic:      *topic,
+		Partition:  *partition,
+		Security:   cfg.KafkaSecurity(),
+		FromOffset: *fromOffset,
+		ToOffset:   *toOffset,
+		FromTime:   parseTime("from-time", *fromTime),
+		ToTime:     parseTime("to-time", *toTime),
+		DryRun:     !*apply,
+		Idle:       *idle,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// кэш процессу replay не нужен
+	cfg.CacheRestoreLimit = 0
+	c, err := app.NewContainer(ctx, cfg)
+	if err != nil {
+		log.Fatalf("container: %v", err)
+	}
+	defer c.Close()
+
+	rep, err := kafka.Replay(ctx, rc, c.Codecs, c.Svc)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(rep); encErr != nil {
+		log.Printf("encode report: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("replay: %v", err)
+	}
+}
+
+func parseTime(name, v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		log.Fatalf("-%s: %v", name, err)
+	}
+	return t
+}
@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// avro-теги берём из json-тегов domain.Order, отдельные DTO не нужны.
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+// FileSchemaRegistry — локальная замена schema registry: схемы лежат в
+// <dir>/<version>.avsc, версия — целое число (как schema id в Confluent).
+type FileSchemaRegistry struct {
+	schemas map[int]avro.Schema
+	latest  int
+}
+
+func NewFileSchemaRegistry(dir string) (*FileSchemaRegistry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.avsc"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.avsc schemas in %s", dir)
+	}
+	r := &FileSchemaRegistry{schemas: make(map[int]avro.Schema, len(files))}
+	for _, f := range files {
+		ver, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(f), ".avsc"))
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: file name must be a version number", f)
+		}
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s, err := avro.Parse(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", f, err)
+		}
+		r.schemas[ver] = s
+	}
+	vers := make([]int, 0, len(r.schemas))
+	for v := range r.schemas {
+		vers = append(vers, v)
+	}
+	sort.Ints(vers)
+	r.latest = vers[len(vers)-1]
+	return r, nil
+}
+
+func (r *FileSchemaRegistry) Schema(version int) (avro.Schema, error) {
+	s, ok := r.schemas[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema version %d", version)
+	}
+	return s, nil
+}
+
+func (r *FileSchemaRegistry) Latest() (int, avro.Schema) { return r.latest, r.schemas[r.latest] }
+
+// Avro пишет последней версией схемы, читает — той, что указана в заголовке
+// schema-version или в префиксе Confluent wire format (0x0 + uint32 schema id).
+type Avro struct {
+	reg *FileSchemaRegistry
+}
+
+func NewAvro(reg *FileSchemaRegistry) Avro { return Avro{reg: reg} }
+
+func (Avro) ContentType() string { return ContentTypeAvro }
+
+func (a Avro) Encode(o domain.Order) ([]byte, string, error) {
+	ver, s := a.reg.Latest()
+	b, err := avroAPI.Marshal(s, o)
+	return b, strconv.Itoa(ver), err
+}
+
+func (a Avro) Decode(data []byte, schemaVersion string) (domain.Order, error) {
+	var ver int
+	switch {
+	case schemaVersion != "":
+		v, err := strconv.Atoi(schemaVersion)
+		if err != nil {
+			return domain.Order{}, fmt.Errorf("bad schema-version %q", schemaVersion)
+		}
+		ver = v
+	case len(data) > 5 && data[0] == 0:
+		ver = int(binary.BigEndian.Uint32(data[1:5]))
+		data = data[5:]
+	default:
+		ver, _ = a.reg.Latest()
+	}
+	s, err := a.reg.Schema(ver)
+	if err != nil {
+		return domain.Order{}, err
+	}
+	var o domain.Order
+	if err := avroAPI.Unmarshal(s, data, &o); err != nil {
+		return domain.Order{}, err
+	}
+	// эволюция Avro-схем решается reader/writer-схемами, после разбора форма текущая
+	o.SchemaVersion = domain.CurrentSchemaVersion
+	return o, nil
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// Заголовки сообщений брокера, по которым выбирается кодек.
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchemaVersion = "schema-version"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// Codec сериализует domain.Order в конкретный формат.
+type Codec interface {
+	ContentType() string
+	// Encode возвращает полезную нагрузку и версию схемы, которой она записана.
+	Encode(o domain.Order) (data []byte, schemaVersion string, err error)
+	Decode(data []byte, schemaVersion string) (domain.Order, error)
+}
+
+// Registry выбирает кодек по content-type. Сообщения без заголовка
+// считаются записанными кодеком по умолчанию (исторически — JSON).
+type Registry struct {
+	byType map[string]Codec
+	def    Codec
+}
+
+func NewRegistry(def Codec, others ...Codec) *Registry {
+	r := &Registry{byType: map[string]Codec{def.ContentType(): def}, def: def}
+	for _, c := range others {
+		r.byType[c.ContentType()] = c
+	}
+	return r
+}
+
+func (r *Registry) Lookup(contentType string) (Codec, bool) {
+	c, ok := r.byType[normalize(contentType)]
+	return c, ok
+}
+
+// Decode разбирает сообщение согласно его заголовкам.
+func (r *Registry) Decode(headers map[string]string, data []byte) (domain.Order, error) {
+	c := r.def
+	if ct := header(headers, HeaderContentType); ct != "" {
+		var ok bool
+		if c, ok = r.Lookup(ct); !ok {
+			return domain.Order{}, fmt.Errorf("unsupported content-type %q", ct)
+		}
+	}
+	o, err := c.Decode(data, header(headers, HeaderSchemaVersion))
+	if err != nil {
+		return domain.Order{}, fmt.Errorf("%s: %w", c.ContentType(), err)
+	}
+	return o, nil
+}
+
+// Encode сериализует заказ и возвращает заголовки, необходимые для Decode.
+func (r *Registry) Encode(contentType string, o domain.Order) ([]byte, map[string]string, error) {
+	c, ok := r.Lookup(contentType)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported content-type %q", contentType)
+	}
+	data, ver, err := c.Encode(o)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := map[string]string{HeaderContentType: c.ContentType()}
+	if ver != "" {
+		h[HeaderSchemaVersion] = ver
+	}
+	return data, h, nil
+}
+
+// заголовки Kafka регистрозависимы, а NATS/HTTP-клиенты любят Content-Type.
+func header(h map[string]string, key string) string {
+	if v, ok := h[key]; ok {
+		return v
+	}
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func normalize(ct string) string {
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
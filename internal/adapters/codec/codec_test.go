@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+func sample() domain.Order {
+	return domain.Order{
+		SchemaVersion: domain.CurrentSchemaVersion,
+		OrderUID:      "u1", TrackNumber: "tn", Entry: "WBIL",
+		Delivery:    domain.Delivery{Name: "Test", Email: "a@b.co"},
+		Payment:     domain.Payment{Transaction: "u1", Currency: "USD", Amount: 10, PaymentDT: 1637907727, GoodsTotal: 9},
+		Items:       []domain.Item{{ChrtID: 1, Name: "x", Price: 9, TotalPrice: 9, Status: 202}},
+		Locale:      "en",
+		SmID:        99,
+		DateCreated: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestRegistry_RoundTrip(t *testing.T) {
+	reg, err := NewFileSchemaRegistry("../../../schemas/avro/order")
+	require.NoError(t, err)
+	r := NewRegistry(JSON{}, Protobuf{}, NewAvro(reg))
+
+	for _, ct := range []string{ContentTypeJSON, ContentTypeProtobuf, ContentTypeAvro} {
+		t.Run(ct, func(t *testing.T) {
+			data, headers, err := r.Encode(ct, sample())
+			require.NoError(t, err)
+			require.Equal(t, ct, headers[HeaderContentType])
+
+			got, err := r.Decode(headers, data)
+			require.NoError(t, err)
+			require.Equal(t, sample(), got)
+		})
+	}
+}
+
+func TestRegistry_DefaultsToJSON(t *testing.T) {
+	r := NewRegistry(JSON{})
+	got, err := r.Decode(nil, []byte(`{"order_uid":"u1"}`))
+	require.NoError(t, err)
+	require.Equal(t, "u1", got.OrderUID)
+
+	_, err = r.Decode(map[string]string{"Content-Type": "text/plain"}, []byte("x"))
+	require.Error(t, err)
+}
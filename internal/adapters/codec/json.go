@@ -0,0 +1,29 @@
+package codec
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// JSON — исторический формат. Версия схемы передаётся полем schema_version
+// внутри документа; старые версии приводятся к текущей через domain.DefaultUpcasters.
+type JSON struct {
+	// Strict отклоняет неизвестные поля в сообщениях без schema_version.
+	Strict bool
+}
+
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+func (JSON) Encode(o domain.Order) ([]byte, string, error) {
+	if o.SchemaVersion == 0 {
+		o.SchemaVersion = domain.CurrentSchemaVersion
+	}
+	b, err := json.Marshal(o)
+	return b, strconv.Itoa(o.SchemaVersion), err
+}
+
+func (c JSON) Decode(data []byte, _ string) (domain.Order, error) {
+	return domain.DecodeOrderJSON(data, c.Strict)
+}
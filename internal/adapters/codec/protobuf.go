@@ -0,0 +1,141 @@
+package codec
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/oziev02/wb/internal/domain"
+	ordersv1 "github.com/oziev02/wb/internal/gen/orders/v1"
+)
+
+// Protobuf — кодек для orders.v1.Order (api/proto/orders/v1/order.proto).
+// Схема эволюционирует через номера полей, поэтому версия заголовка не используется.
+type Protobuf struct{}
+
+func (Protobuf) ContentType() string { return ContentTypeProtobuf }
+
+func (Protobuf) Encode(o domain.Order) ([]byte, string, error) {
+	b, err := proto.Marshal(OrderToProto(o))
+	return b, "", err
+}
+
+func (Protobuf) Decode(data []byte, _ string) (domain.Order, error) {
+	var m ordersv1.Order
+	if err := proto.Unmarshal(data, &m); err != nil {
+		return domain.Order{}, err
+	}
+	return OrderFromProto(&m), nil
+}
+
+func OrderToProto(o domain.Order) *ordersv1.Order {
+	items := make([]*ordersv1.Item, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, &ordersv1.Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return &ordersv1.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &ordersv1.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &ordersv1.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OofShard,
+	}
+}
+
+func OrderFromProto(m *ordersv1.Order) domain.Order {
+	items := make([]domain.Item, 0, len(m.GetItems()))
+	for _, it := range m.GetItems() {
+		items = append(items, domain.Item{
+			ChrtID:      int(it.GetChrtId()),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			RID:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        int(it.GetNmId()),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	d, p := m.GetDelivery(), m.GetPayment()
+	o := domain.Order{
+		SchemaVersion: domain.CurrentSchemaVersion,
+		OrderUID:      m.GetOrderUid(),
+		TrackNumber:   m.GetTrackNumber(),
+		Entry:         m.GetEntry(),
+		Delivery: domain.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: domain.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDT:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		},
+		Items:             items,
+		Locale:            m.GetLocale(),
+		InternalSignature: m.GetInternalSignature(),
+		CustomerID:        m.GetCustomerId(),
+		DeliveryService:   m.GetDeliveryService(),
+		ShardKey:          m.GetShardkey(),
+		SmID:              int(m.GetSmId()),
+		OofShard:          m.GetOofShard(),
+	}
+	if m.GetDateCreated() != nil {
+		o.DateCreated = m.GetDateCreated().AsTime()
+	}
+	return o
+}
@@ -0,0 +1,205 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrOpen возвращается без обращения к БД, пока breaker открыт.
+var ErrOpen = fmt.Errorf("circuit breaker open: %w", domain.ErrUnavailable)
+
+type Config struct {
+	// Failures — сколько неудач подряд размыкают цепь.
+	Failures int
+	// SlowCall — вызов дольше порога считается неудачным, даже если успешен.
+	SlowCall time.Duration
+	// OpenTimeout — через сколько после размыкания пропустить пробный вызов.
+	OpenTimeout time.Duration
+}
+
+// Repo — декоратор domain.OrderRepository с circuit breaker.
+// В half-open пропускается один пробный вызов: успех замыкает цепь, неудача снова размыкает.
+type Repo struct {
+	next domain.OrderRepository
+	cfg  Config
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	listeners []func(from, to State)
+}
+
+func New(next domain.OrderRepository, cfg Config) *Repo {
+	return &Repo{next: next, cfg: cfg, now: time.Now}
+}
+
+// OnStateChange регистрирует обработчик переходов. Вызывается синхронно
+// в горутине вызова, который изменил состояние.
+func (r *Repo) OnStateChange(fn func(from, to State)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+func (r *Repo) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+func (r *Repo) UpsertOrder(o domain.Order) (domain.UpsertResult, error) {
+	if err := r.acquire(); err != nil {
+		return domain.UpsertResult{}, err
+	}
+	start := r.now()
+	res, err := r.next.UpsertOrder(o)
+	r.release(start, err)
+	return res, err
+}
+
+func (r *Repo) GetByID(id string) (domain.Order, bool, error) {
+	if err := r.acquire(); err != nil {
+		return domain.Order{}, false, err
+	}
+	start := r.now()
+	o, ok, err := r.next.GetByID(id)
+	r.release(start, err)
+	return o, ok, err
+}
+
+func (r *Repo) LoadAll(limit int) ([]domain.Order, error) {
+	if err := r.acquire(); err != nil {
+		return nil, err
+	}
+	start := r.now()
+	out, err := r.next.LoadAll(limit)
+	r.release(start, err)
+	return out, err
+}
+
+func (r *Repo) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	if err := r.acquire(); err != nil {
+		return nil, err
+	}
+	start := r.now()
+	out, err := r.next.Search(f)
+	r.release(start, err)
+	return out, err
+}
+
+func (r *Repo) acquire() error {
+	r.mu.Lock()
+	var notify func()
+	defer func() {
+		r.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+
+	switch r.state {
+	case Closed:
+		return nil
+	case Open:
+		if r.now().Sub(r.openedAt) < r.cfg.OpenTimeout {
+			metrics.DBBreakerRejected.Inc()
+			return ErrOpen
+		}
+		notify = r.transition(HalfOpen)
+		r.probing = true
+		return nil
+	default: // HalfOpen: пробный вызов уже идёт
+		if r.probing {
+			metrics.DBBreakerRejected.Inc()
+			return ErrOpen
+		}
+		r.probing = true
+		return nil
+	}
+}
+
+func (r *Repo) release(start time.Time, err error) {
+	// отвергнутый или стёртый по запросу субъекта заказ — ответ БД, а не её отказ
+	failed := (err != nil && !errors.Is(err, domain.ErrInvalidOrder) && !errors.Is(err, domain.ErrErased)) ||
+		(r.cfg.SlowCall > 0 && r.now().Sub(start) > r.cfg.SlowCall)
+
+	r.mu.Lock()
+	var notify func()
+	defer func() {
+		r.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+
+	if r.state == HalfOpen {
+		r.probing = false
+		if failed {
+			notify = r.trip()
+		} else {
+			r.failures = 0
+			notify = r.transition(Closed)
+		}
+		return
+	}
+	if !failed {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.state == Closed && r.failures >= r.cfg.Failures {
+		notify = r.trip()
+	}
+}
+
+func (r *Repo) trip() func() {
+	r.openedAt = r.now()
+	return r.transition(Open)
+}
+
+// transition меняет состояние под мьютексом и возвращает уведомление,
+// которое вызывающий выполняет уже после разблокировки.
+func (r *Repo) transition(to State) func() {
+	from := r.state
+	if from == to {
+		return nil
+	}
+	r.state = to
+	metrics.DBBreakerState.Set(float64(to))
+	listeners := slices.Clone(r.listeners)
+	return func() {
+		for _, fn := range listeners {
+			fn(from, to)
+		}
+	}
+}
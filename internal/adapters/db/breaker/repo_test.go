@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/mocks"
+)
+
+func TestRepo_TripAndRecover(t *testing.T) {
+	next := mocks.NewOrderRepository(t)
+	dbErr := errors.New("connection refused")
+	next.On("GetByID", "u1").Return(domain.Order{}, false, dbErr).Times(2)
+
+	now := time.Now()
+	r := New(next, Config{Failures: 2, OpenTimeout: time.Minute})
+	r.now = func() time.Time { return now }
+	var transitions []State
+	r.OnStateChange(func(_, to State) { transitions = append(transitions, to) })
+
+	for i := 0; i < 2; i++ {
+		_, _, err := r.GetByID("u1")
+		require.ErrorIs(t, err, dbErr)
+	}
+	require.Equal(t, Open, r.State())
+
+	// открыт — в БД не ходим
+	_, _, err := r.GetByID("u1")
+	require.ErrorIs(t, err, ErrOpen)
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+
+	// по истечении таймаута пропускаем пробный вызов; успех замыкает цепь
+	now = now.Add(time.Minute)
+	next.On("GetByID", "u2").Return(domain.Order{OrderUID: "u2"}, true, nil).Once()
+	o, ok, err := r.GetByID("u2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "u2", o.OrderUID)
+	require.Equal(t, Closed, r.State())
+	require.Equal(t, []State{Open, HalfOpen, Closed}, transitions)
+}
+
+func TestRepo_SlowCallsCountAsFailures(t *testing.T) {
+	next := mocks.NewOrderRepository(t)
+	next.On("LoadAll", 10).Return([]domain.Order{}, nil).Once()
+
+	now := time.Now()
+	r := New(next, Config{Failures: 1, SlowCall: time.Second, OpenTimeout: time.Minute})
+	calls := 0
+	r.now = func() time.Time {
+		calls++
+		return now.Add(time.Duration(calls) * 2 * time.Second)
+	}
+
+	_, err := r.LoadAll(10)
+	require.NoError(t, err)
+	require.Equal(t, Open, r.State())
+}
+
+func TestRepo_RejectedOrdersAreNotFailures(t *testing.T) {
+	next := mocks.NewOrderRepository(t)
+	rejected := fmt.Errorf("%w: no shard for shard key", domain.ErrInvalidOrder)
+	next.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{}, rejected).Times(3)
+
+	r := New(next, Config{Failures: 2, OpenTimeout: time.Minute})
+	for i := 0; i < 3; i++ {
+		_, err := r.UpsertOrder(domain.Order{OrderUID: "u1"})
+		require.ErrorIs(t, err, domain.ErrInvalidOrder)
+	}
+	require.Equal(t, Closed, r.State())
+}
+
+func TestRepo_ErasedOrdersAreNotFailures(t *testing.T) {
+	next := mocks.NewOrderRepository(t)
+	next.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{}, domain.ErrErased).Times(5)
+
+	r := New(next, Config{Failures: 2, OpenTimeout: time.Minute})
+	for i := 0; i < 5; i++ {
+		_, err := r.UpsertOrder(domain.Order{OrderUID: "u1"})
+		require.ErrorIs(t, err, domain.ErrErased)
+	}
+	require.Equal(t, Closed, r.State(), "redelivered erased orders do not trip the breaker")
+}
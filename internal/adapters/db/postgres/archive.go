@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Archiver убирает из рабочих таблиц заказы старше срока хранения: переносит
+// их в orders_archive или, если задан каталог, выгружает туда сжатым NDJSON.
+// Дочерние строки (deliveries, payments, items) удаляются каскадом.
+type Archiver struct {
+	pool *pgxpool.Pool
+	// dir == "" — перенос в orders_archive.
+	dir string
+	now func() time.Time
+}
+
+func NewTableArchiver(pool *pgxpool.Pool) *Archiver {
+	return &Archiver{pool: pool, now: time.Now}
+}
+
+func NewFileArchiver(pool *pgxpool.Pool, dir string) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("archive dir: %w", err)
+	}
+	return &Archiver{pool: pool, dir: dir, now: time.Now}, nil
+}
+
+// MoveBatch переносит до limit заказов, созданных раньше before, начиная с самых старых.
+func (a *Archiver) MoveBatch(ctx context.Context, before time.Time, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	if a.dir == "" {
+		return a.toTable(ctx, before, limit)
+	}
+	return a.toFile(ctx, before, limit)
+}
+
+// toTable — одним запросом: копия в архив и удаление видят один снимок,
+// так что заказ не может пропасть между ними.
+func (a *Archiver) toTable(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := a.pool.Exec(ctx, `
+WITH batch AS (
+    SELECT order_uid, date_created FROM orders
+    WHERE date_created < $1
+    ORDER BY date_created
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+), archived AS (
+    INSERT INTO orders_archive (order_uid, customer_id, date_created, updated_at, raw_json, raw_digest,
+                                pii_kek, pii_dek, email_idx, erased_at)
+    SELECT o.order_uid, o.customer_id, o.date_created, o.updated_at, o.raw_json, o.raw_digest,
+           o.pii_kek, o.pii_dek, d.email_idx, o.erased_at
+    FROM orders o JOIN batch b ON b.order_uid = o.order_uid AND b.date_created = o.date_created
+    LEFT JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+    ON CONFLICT (order_uid) DO UPDATE SET
+      customer_id=EXCLUDED.customer_id, date_created=EXCLUDED.date_created, updated_at=EXCLUDED.updated_at,
+      raw_json=EXCLUDED.raw_json, raw_digest=EXCLUDED.raw_digest, pii_kek=EXCLUDED.pii_kek,
+      pii_dek=EXCLUDED.pii_dek, email_idx=EXCLUDED.email_idx, erased_at=EXCLUDED.erased_at,
+      archived_at=now()
+    RETURNING order_uid
+), keys AS (
+    DELETE FROM order_keys k USING batch b WHERE k.order_uid = b.order_uid AND k.date_created = b.date_created
+)
+DELETE FROM orders o USING batch b
+WHERE o.order_uid = b.order_uid AND o.date_created = b.date_created
+  AND o.order_uid IN (SELECT order_uid FROM archived)
+`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("archive orders: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// exportedOrder — строка файла выгрузки. Заказ в том виде, как хранился:
+// контакты зашифрованы, для расшифровки нужен ключ pii_kek из keyring.
+type exportedOrder struct {
+	OrderUID    string          `json:"order_uid"`
+	DateCreated time.Time       `json:"date_created"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ErasedAt    *time.Time      `json:"erased_at,omitempty"`
+	PIIKek      *string         `json:"pii_kek,omitempty"`
+	PIIDek      []byte          `json:"pii_dek,omitempty"`
+	Order       json.RawMessage `json:"order"`
+}
+
+// toFile пишет батч в файл и удаляет заказы в той же транзакции, где они
+// заблокированы. Файл дописывается до коммита: если коммит не пройдёт, заказы
+// выгрузятся повторно в следующий файл — потребители выгрузки дедуплицируют по order_uid.
+func (a *Archiver) toFile(ctx context.Context, before time.Time, limit int) (int, error) {
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	rows, err := tx.Query(ctx, `
+SELECT order_uid, date_created, updated_at, erased_at, pii_kek, pii_dek, raw_json
+FROM orders
+WHERE date_created < $1
+ORDER BY date_created
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select expired: %w", err)
+	}
+	batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (exportedOrder, error) {
+		var e exportedOrder
+		err := row.Scan(&e.OrderUID, &e.DateCreated, &e.UpdatedAt, &e.ErasedAt, &e.PIIKek, &e.PIIDek, &e.Order)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("select expired: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	if err := a.writeFile(batch); err != nil {
+		return 0, err
+	}
+	uids := make([]string, len(batch))
+	for i, e := range batch {
+		uids[i] = e.OrderUID
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM orders WHERE order_uid = ANY($1) AND date_created < $2`, uids, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete exported: %w", err)
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM order_keys WHERE order_uid = ANY($1) AND date_created < $2`, uids, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete exported keys: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(batch), nil
+}
+
+// writeFile пишет orders-<время>.ndjson.gz через временный файл и rename:
+// недописанный файл не появится в каталоге под итоговым именем.
+func (a *Archiver) writeFile(batch []exportedOrder) (err error) {
+	name := filepath.Join(a.dir, "orders-"+a.now().UTC().Format("20060102T150405.000000000")+".ndjson.gz")
+	f, err := os.OpenFile(name+".part", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(name + ".part")
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	zw := gzip.NewWriter(bw)
+	enc := json.NewEncoder(zw)
+	for _, e := range batch {
+		if err = enc.Encode(e); err != nil {
+			return fmt.Errorf("archive file: %w", err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	if err = os.Rename(name+".part", name); err != nil {
+		return fmt.Errorf("archive file: %w", err)
+	}
+	return nil
+}
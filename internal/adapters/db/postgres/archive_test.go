@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readExport(t *testing.T, path string) []exportedOrder {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	var out []exportedOrder
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		var e exportedOrder
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		out = append(out, e)
+	}
+	require.NoError(t, sc.Err())
+	return out
+}
+
+func TestArchiver_WriteFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "archive")
+	a, err := NewFileArchiver(nil, dir)
+	require.NoError(t, err)
+	at := time.Date(2025, 3, 1, 2, 3, 4, 5, time.FixedZone("MSK", 3*3600))
+	a.now = func() time.Time { return at }
+
+	kek := "a1"
+	batch := []exportedOrder{
+		{OrderUID: "u1", DateCreated: at.Add(-time.Hour).UTC(), UpdatedAt: at.UTC(), PIIKek: &kek, PIIDek: []byte{1, 2},
+			Order: json.RawMessage(`{"order_uid":"u1"}`)},
+		{OrderUID: "u2", DateCreated: at.Add(-2 * time.Hour).UTC(), UpdatedAt: at.UTC(), Order: json.RawMessage(`{"order_uid":"u2"}`)},
+	}
+	require.NoError(t, a.writeFile(batch))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no .part file is left behind")
+	name := entries[0].Name()
+	require.Equal(t, "orders-20250228T230304.000000005.ndjson.gz", name)
+	require.Equal(t, batch, readExport(t, filepath.Join(dir, name)))
+}
+
+func TestArchiver_WriteFileNeverOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewFileArchiver(nil, dir)
+	require.NoError(t, err)
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return at }
+	part := filepath.Join(dir, "orders-20250301T000000.000000000.ndjson.gz.part")
+	require.NoError(t, os.WriteFile(part, []byte("other writer"), 0o640))
+
+	err = a.writeFile([]exportedOrder{{OrderUID: "u1", Order: json.RawMessage(`{}`)}})
+	require.ErrorContains(t, err, "archive file")
+	data, err := os.ReadFile(part)
+	require.NoError(t, err)
+	require.Equal(t, "other writer", string(data), "a concurrent .part file is not touched")
+	_, err = os.Stat(filepath.Join(dir, "orders-20250301T000000.000000000.ndjson.gz"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewFileArchiver_BadDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err := NewFileArchiver(nil, filepath.Join(file, "archive"))
+	require.ErrorContains(t, err, "archive dir")
+}
@@ -2,44 +2,87 @@ package postgres
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/fieldcrypt"
 )
 
 type OrderRepo struct {
 	pool *pgxpool.Pool
+	// keys == nil — контактные данные пишутся открытыми.
+	keys *fieldcrypt.Keyring
+	// archive — GetByID ищет в orders_archive заказы, которых нет в orders.
+	archive bool
 }
 
-func NewOrderRepo(pool *pgxpool.Pool) *OrderRepo { return &OrderRepo{pool: pool} }
+type Option func(*OrderRepo)
 
-func (r *OrderRepo) UpsertOrder(o domain.Order) error {
+// WithKeyring включает шифрование контактных полей Delivery (см. pii.go).
+func WithKeyring(k *fieldcrypt.Keyring) Option {
+	return func(r *OrderRepo) { r.keys = k }
+}
+
+// WithArchiveFallback включает чтение из orders_archive (см. Archiver) в GetByID.
+func WithArchiveFallback() Option {
+	return func(r *OrderRepo) { r.archive = true }
+}
+
+func NewOrderRepo(pool *pgxpool.Pool, opts ...Option) *OrderRepo {
+	r := &OrderRepo{pool: pool}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *OrderRepo) UpsertOrder(o domain.Order) (domain.UpsertResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	raw, err := o.RawJSON()
+	plain, err := o.RawJSON()
+	if err != nil {
+		return domain.UpsertResult{}, fmt.Errorf("marshal raw: %w", err)
+	}
+	stored, kid, wrapped, err := r.sealOrder(o)
 	if err != nil {
-		return fmt.Errorf("marshal raw: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("encrypt: %w", err)
+	}
+	raw, err := stored.RawJSON()
+	if err != nil {
+		return domain.UpsertResult{}, fmt.Errorf("marshal raw: %w", err)
 	}
 
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("begin: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("begin: %w", err)
 	}
 	defer func() {
 		_ = tx.Rollback(ctx) // безопасно: если уже commit — no-op
 	}()
 
-	_, err = tx.Exec(ctx, `
+	moved, err := moveOrder(ctx, tx, o)
+	if err != nil {
+		return domain.UpsertResult{}, err
+	}
+
+	// RETURNING пуст, если заказ не изменился (повторная доставка) — тогда и событие не нужно.
+	var (
+		inserted bool
+		res      = domain.UpsertResult{Changed: true}
+	)
+	err = tx.QueryRow(ctx, `
 INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id,
-                    delivery_service, shardkey, sm_id, date_created, oof_shard, raw_json)
-VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-ON CONFLICT (order_uid) DO UPDATE SET
+                    delivery_service, shardkey, sm_id, date_created, oof_shard, raw_json, updated_at,
+                    raw_digest, pii_kek, pii_dek)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+ON CONFLICT (order_uid, date_created) DO UPDATE SET
   track_number=EXCLUDED.track_number,
   entry=EXCLUDED.entry,
   locale=EXCLUDED.locale,
@@ -48,100 +91,258 @@ ON CONFLICT (order_uid) DO UPDATE SET
   delivery_service=EXCLUDED.delivery_service,
   shardkey=EXCLUDED.shardkey,
   sm_id=EXCLUDED.sm_id,
-  date_created=EXCLUDED.date_created,
   oof_shard=EXCLUDED.oof_shard,
-  raw_json=EXCLUDED.raw_json
+  raw_json=EXCLUDED.raw_json,
+  updated_at=EXCLUDED.updated_at,
+  raw_digest=EXCLUDED.raw_digest,
+  pii_kek=EXCLUDED.pii_kek,
+  pii_dek=EXCLUDED.pii_dek,
+  pii_failed_kek=NULL
+WHERE orders.erased_at IS NULL AND orders.raw_digest IS DISTINCT FROM EXCLUDED.raw_digest
+RETURNING (xmax = 0), updated_at
 `, o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
-		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, raw)
+		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, raw, updatedAt(o),
+		r.rawDigest(plain), kid, wrapped).Scan(&inserted, &res.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var erased bool
+		if err := tx.QueryRow(ctx, `SELECT erased_at IS NOT NULL, updated_at FROM orders WHERE order_uid=$1 AND date_created=$2`,
+			o.OrderUID, o.DateCreated).Scan(&erased, &res.UpdatedAt); err != nil {
+			return domain.UpsertResult{}, fmt.Errorf("check erased: %w", err)
+		}
+		if erased {
+			return domain.UpsertResult{}, domain.ErrErased
+		}
+		res.Changed = false
+		return res, nil
+	}
 	if err != nil {
-		return fmt.Errorf("upsert orders: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("upsert orders: %w", err)
+	}
+	if inserted {
+		// заказ мог уйти в архив уже обезличенным — повторная доставка не должна вернуть контакты
+		var erased bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders_archive WHERE order_uid=$1 AND erased_at IS NOT NULL)`,
+			o.OrderUID).Scan(&erased)
+		if err != nil {
+			return domain.UpsertResult{}, fmt.Errorf("check archive: %w", err)
+		}
+		if erased {
+			return domain.UpsertResult{}, domain.ErrErased
+		}
 	}
 
 	_, err = tx.Exec(ctx, `
-INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
-VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-ON CONFLICT (order_uid) DO UPDATE SET
+INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email, email_idx)
+VALUES ($1,$10,$2,$3,$4,$5,$6,$7,$8,$9)
+ON CONFLICT (order_uid, date_created) DO UPDATE SET
   name=EXCLUDED.name, phone=EXCLUDED.phone, zip=EXCLUDED.zip, city=EXCLUDED.city,
-  address=EXCLUDED.address, region=EXCLUDED.region, email=EXCLUDED.email
-`, o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
-		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email)
+  address=EXCLUDED.address, region=EXCLUDED.region, email=EXCLUDED.email, email_idx=EXCLUDED.email_idx
+`, o.OrderUID, stored.Delivery.Name, stored.Delivery.Phone, stored.Delivery.Zip, stored.Delivery.City,
+		stored.Delivery.Address, stored.Delivery.Region, stored.Delivery.Email, r.emailIndex(o.Delivery.Email), o.DateCreated)
 	if err != nil {
-		return fmt.Errorf("upsert deliveries: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("upsert deliveries: %w", err)
 	}
 
 	_, err = tx.Exec(ctx, `
-INSERT INTO payments (order_uid, transaction, request_id, currency, provider,
+INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider,
                       amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
-VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-ON CONFLICT (order_uid) DO UPDATE SET
+VALUES ($1,$12,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+ON CONFLICT (order_uid, date_created) DO UPDATE SET
   transaction=EXCLUDED.transaction, request_id=EXCLUDED.request_id, currency=EXCLUDED.currency,
   provider=EXCLUDED.provider, amount=EXCLUDED.amount, payment_dt=EXCLUDED.payment_dt,
   bank=EXCLUDED.bank, delivery_cost=EXCLUDED.delivery_cost, goods_total=EXCLUDED.goods_total, custom_fee=EXCLUDED.custom_fee
 `, o.OrderUID, o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
-		o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank, o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee)
+		o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank, o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee,
+		o.DateCreated)
 	if err != nil {
-		return fmt.Errorf("upsert payments: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("upsert payments: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, `DELETE FROM items WHERE order_uid=$1`, o.OrderUID)
+	_, err = tx.Exec(ctx, `DELETE FROM items WHERE order_uid=$1 AND date_created=$2`, o.OrderUID, o.DateCreated)
 	if err != nil {
-		return fmt.Errorf("delete items: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("delete items: %w", err)
 	}
 
 	for _, it := range o.Items {
 		_, err = tx.Exec(ctx, `
-INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
-VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-`, o.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale, it.Size,
+INSERT INTO items (order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+`, o.OrderUID, o.DateCreated, it.ChrtID, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale, it.Size,
 			it.TotalPrice, it.NmID, it.Brand, it.Status)
 		if err != nil {
-			return fmt.Errorf("insert item: %w", err)
+			return domain.UpsertResult{}, fmt.Errorf("insert item: %w", err)
 		}
 	}
 
+	typ := domain.EventOrderUpdated
+	if inserted && !moved {
+		typ = domain.EventOrderCreated
+	}
+	if err = insertOutbox(ctx, tx, domain.NewOrderEvent(typ, stored, time.Now().UTC()), kid, wrapped); err != nil {
+		return domain.UpsertResult{}, err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit: %w", err)
+		return domain.UpsertResult{}, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+// moveOrder сверяет ключ секции с order_keys. Если у заказа сменился date_created,
+// прежняя строка удаляется (дочерние — каскадом), и заказ пишется заново в свою
+// секцию. Строка order_keys блокируется до конца транзакции, так что параллельная
+// доставка того же заказа ждёт. true — заказ уже был сохранён под другой датой.
+func moveOrder(ctx context.Context, tx pgx.Tx, o domain.Order) (bool, error) {
+	var prev time.Time
+	err := tx.QueryRow(ctx, `
+INSERT INTO order_keys (order_uid, date_created) VALUES ($1, $2)
+ON CONFLICT (order_uid) DO UPDATE SET order_uid = EXCLUDED.order_uid
+RETURNING date_created
+`, o.OrderUID, o.DateCreated).Scan(&prev)
+	if err != nil {
+		return false, fmt.Errorf("order key: %w", err)
+	}
+	// Postgres хранит микросекунды
+	if prev.Equal(o.DateCreated.Truncate(time.Microsecond)) {
+		return false, nil
+	}
+	var erased bool
+	err = tx.QueryRow(ctx, `SELECT erased_at IS NOT NULL FROM orders WHERE order_uid=$1 AND date_created=$2`,
+		o.OrderUID, prev).Scan(&erased)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("check erased: %w", err)
+	}
+	if erased {
+		return false, domain.ErrErased
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM orders WHERE order_uid=$1 AND date_created=$2`, o.OrderUID, prev)
+	if err != nil {
+		return false, fmt.Errorf("move order: %w", err)
 	}
-	return nil
+	if _, err = tx.Exec(ctx, `UPDATE order_keys SET date_created=$2 WHERE order_uid=$1`, o.OrderUID, o.DateCreated); err != nil {
+		return false, fmt.Errorf("order key: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
 }
 
+// GetByID находит секцию по order_keys: подзапрос вычисляется до сканирования,
+// и планировщик отсекает остальные секции во время выполнения.
 func (r *OrderRepo) GetByID(id string) (domain.Order, bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	var raw []byte
-	err := r.pool.QueryRow(ctx, `SELECT raw_json FROM orders WHERE order_uid=$1`, id).Scan(&raw)
+	o, err := r.scanOrder(r.pool.QueryRow(ctx, `
+SELECT `+orderColumns+` FROM orders
+WHERE order_uid=$1 AND date_created = (SELECT date_created FROM order_keys WHERE order_uid=$1)`, id))
+	if errors.Is(err, pgx.ErrNoRows) && r.archive {
+		o, err = r.scanOrder(r.pool.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders_archive WHERE order_uid=$1`, id))
+	}
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return domain.Order{}, false, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Order{}, false, nil
 		}
 		return domain.Order{}, false, err
 	}
-	var o domain.Order
-	if err := json.Unmarshal(raw, &o); err != nil {
-		return domain.Order{}, false, fmt.Errorf("unmarshal: %w", err)
-	}
 	return o, true, nil
 }
 
 func (r *OrderRepo) LoadAll(limit int) ([]domain.Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	rows, err := r.pool.Query(ctx, `SELECT raw_json FROM orders ORDER BY date_created DESC LIMIT $1`, limit)
+	// в default-секции могут быть любые даты, поэтому упорядоченного Append нет:
+	// план — MergeAppend по индексам date_created всех секций. Каждая секция
+	// открывается, но индексные сканы ленивые, и LIMIT читает из каждой лишь начало
+	rows, err := r.pool.Query(ctx, `SELECT `+orderColumns+` FROM orders ORDER BY date_created DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanOrders(rows)
+}
+
+func (r *OrderRepo) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.CustomerID != "" {
+		add("customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Entry != "" {
+		add("entry = $%d", f.Entry)
+	}
+	if f.TrackNumber != "" {
+		add("track_number = $%d", f.TrackNumber)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("date_created >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("date_created < $%d", f.CreatedTo)
+	}
+	q := `SELECT ` + orderColumns + ` FROM orders`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	q += fmt.Sprintf(" ORDER BY date_created DESC LIMIT $%d", len(args))
+
+	rows, err := r.pool.Query(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
+	return r.scanOrders(rows)
+}
+
+// orderColumns — колонки, которые читает scanOrder.
+const orderColumns = `raw_json, updated_at, pii_kek, pii_dek`
+
+func (r *OrderRepo) scanOrder(row pgx.Row) (domain.Order, error) {
+	var (
+		raw     []byte
+		updated time.Time
+		kid     *string
+		wrapped []byte
+	)
+	if err := row.Scan(&raw, &updated, &kid, &wrapped); err != nil {
+		return domain.Order{}, err
+	}
+	o, err := domain.DecodeOrderJSON(raw, false)
+	if err != nil {
+		return domain.Order{}, fmt.Errorf("unmarshal: %w", err)
+	}
+	if err := r.openOrder(&o, kid, wrapped); err != nil {
+		return domain.Order{}, err
+	}
+	o.UpdatedAt = updated
+	return o, nil
+}
+
+func (r *OrderRepo) scanOrders(rows pgx.Rows) ([]domain.Order, error) {
 	defer rows.Close()
 	var out []domain.Order
 	for rows.Next() {
-		var raw []byte
-		if err := rows.Scan(&raw); err != nil {
+		o, err := r.scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		var o domain.Order
-		if err := json.Unmarshal(raw, &o); err != nil {
-			return nil, fmt.Errorf("unmarshal: %w", err)
-		}
 		out = append(out, o)
 	}
 	return out, rows.Err()
 }
+
+// updatedAt — время изменения, проставленное сервисом; для старых вызовов — now.
+func updatedAt(o domain.Order) time.Time {
+	if o.UpdatedAt.IsZero() {
+		return time.Now().UTC()
+	}
+	return o.UpdatedAt
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/fieldcrypt"
+)
+
+// OutboxRepo выдаёт неотправленные события outbox для релея.
+type OutboxRepo struct {
+	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
+}
+
+// NewOutboxRepo — keys тот же, что у OrderRepo этой базы; nil — шифрование выключено.
+func NewOutboxRepo(pool *pgxpool.Pool, keys *fieldcrypt.Keyring) *OutboxRepo {
+	return &OutboxRepo{pool: pool, keys: keys}
+}
+
+// insertOutbox вызывается внутри транзакции UpsertOrder. Заказ в событии — в том
+// виде, как сохранён в orders: при шифровании контакты запечатаны ключом kid.
+func insertOutbox(ctx context.Context, tx pgx.Tx, e domain.OrderEvent, kid *string, wrapped []byte) error {
+	payload, err := e.Payload()
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+INSERT INTO outbox (event_type, aggregate_id, payload, created_at, pii_kek, pii_dek) VALUES ($1,$2,$3,$4,$5,$6)
+`, e.Type, e.OrderUID, payload, e.OccurredAt, kid, wrapped)
+	if err != nil {
+		return fmt.Errorf("insert outbox: %w", err)
+	}
+	return nil
+}
+
+// RelayPending блокирует до limit неотправленных событий (SKIP LOCKED — несколько
+// релеев не мешают друг другу), передаёт их в publish и при успехе удаляет в той же
+// транзакции: история событий — в брокере, outbox не растёт без предела.
+// Если publish или commit упали, события останутся в outbox и уйдут повторно (at-least-once).
+func (r *OutboxRepo) RelayPending(ctx context.Context, limit int,
+	publish func(context.Context, []domain.OutboxRecord) error,
+) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	rows, err := tx.Query(ctx, `
+SELECT id, event_type, aggregate_id, payload, pii_kek, pii_dek FROM outbox
+WHERE sent_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select outbox: %w", err)
+	}
+	var recs []domain.OutboxRecord
+	var ids []int64
+	for rows.Next() {
+		var (
+			rec     domain.OutboxRecord
+			kid     *string
+			wrapped []byte
+		)
+		if err := rows.Scan(&rec.ID, &rec.Type, &rec.Key, &rec.Payload, &kid, &wrapped); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if rec.Payload, err = r.openPayload(rec.Payload, kid, wrapped); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("outbox event %d: %w", rec.ID, err)
+		}
+		recs = append(recs, rec)
+		ids = append(ids, rec.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(ctx, recs); err != nil {
+		return 0, fmt.Errorf("publish: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("delete sent: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(recs), nil
+}
+
+// openPayload расшифровывает контакты в заказе события; kid == nil — событие
+// записано открытым (до включения шифрования или обезличенное).
+func (r *OutboxRepo) openPayload(payload []byte, kid *string, wrapped []byte) ([]byte, error) {
+	if kid == nil {
+		return payload, nil
+	}
+	var e domain.OrderEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if err := openDelivery(r.keys, &e.Order, kid, wrapped); err != nil {
+		return nil, err
+	}
+	return e.Payload()
+}
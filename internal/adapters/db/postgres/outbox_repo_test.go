@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+func outboxCount(t *testing.T, r *OutboxRepo) int {
+	t.Helper()
+	var n int
+	require.NoError(t, r.pool.QueryRow(context.Background(), `SELECT count(*) FROM outbox`).Scan(&n))
+	return n
+}
+
+func TestOutboxRepo_RelayDeletesSentEvents(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	require.NoError(t, pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		for _, uid := range []string{"u1", "u2", "u3"} {
+			ev := domain.NewOrderEvent(domain.EventOrderCreated, domain.Order{OrderUID: uid}, time.Now().UTC())
+			if err := insertOutbox(ctx, tx, ev, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	r := NewOutboxRepo(pool, nil)
+
+	// неудачная публикация оставляет события в outbox
+	_, err := r.RelayPending(ctx, 2, func(context.Context, []domain.OutboxRecord) error { return errors.New("broker down") })
+	require.Error(t, err)
+	require.Equal(t, 3, outboxCount(t, r))
+
+	var sent []string
+	publish := func(_ context.Context, recs []domain.OutboxRecord) error {
+		for _, rec := range recs {
+			sent = append(sent, rec.Key)
+		}
+		return nil
+	}
+	n, err := r.RelayPending(ctx, 2, publish)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, 1, outboxCount(t, r), "relayed events are deleted")
+
+	n, err = r.RelayPending(ctx, 2, publish)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Zero(t, outboxCount(t, r))
+	require.Equal(t, []string{"u1", "u2", "u3"}, sent)
+}
+
+// контакты, которых не должно быть в открытом виде в outbox.payload
+var piiValues = []string{"Test Testov", "+79720000045", "Ploshad Mira 15", "test@gmail.com"}
+
+func TestOutboxRepo_OpenPayload(t *testing.T) {
+	kr := testKeyring(t, "a1", "a1")
+	o := piiOrder("u1")
+	stored, kid, wrapped, err := NewOrderRepo(nil, WithKeyring(kr)).sealOrder(o)
+	require.NoError(t, err)
+	payload, err := domain.NewOrderEvent(domain.EventOrderCreated, stored, time.Now().UTC()).Payload()
+	require.NoError(t, err)
+	for _, v := range piiValues {
+		require.NotContains(t, string(payload), v)
+	}
+
+	opened, err := NewOutboxRepo(nil, kr).openPayload(payload, kid, wrapped)
+	require.NoError(t, err)
+	var e domain.OrderEvent
+	require.NoError(t, json.Unmarshal(opened, &e))
+	require.Equal(t, o.Delivery, e.Order.Delivery)
+
+	// событие без ключа (обезличенное или записанное до шифрования) уходит как есть
+	same, err := NewOutboxRepo(nil, nil).openPayload(payload, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, payload, same)
+
+	_, err = NewOutboxRepo(nil, nil).openPayload(payload, kid, wrapped)
+	require.ErrorContains(t, err, "no keyring is configured")
+}
+
+func TestOutboxRepo_NoPlaintextPIIAtRest(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	kr := testKeyring(t, "a1", "a1")
+	o := piiOrder("u1")
+	_, err := NewOrderRepo(pool, WithKeyring(kr)).UpsertOrder(o)
+	require.NoError(t, err)
+
+	var stored string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT payload::text FROM outbox WHERE aggregate_id='u1'`).Scan(&stored))
+	for _, v := range piiValues {
+		require.NotContains(t, stored, v)
+	}
+
+	var published []domain.OutboxRecord
+	_, err = NewOutboxRepo(pool, kr).RelayPending(ctx, 10, func(_ context.Context, recs []domain.OutboxRecord) error {
+		published = recs
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	var e domain.OrderEvent
+	require.NoError(t, json.Unmarshal(published[0].Payload, &e))
+	require.Equal(t, o.Delivery, e.Order.Delivery, "consumers get the contacts decrypted")
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// partitionedTables — таблицы, секционированные по date_created. Порядок важен:
+// orders создаётся первой, а отсоединяется последней — на неё ссылаются остальные.
+var partitionedTables = []string{"orders", "deliveries", "payments", "items"}
+
+const partitionLayout = "2006_01"
+
+// Partitioner обслуживает помесячные секции: заранее создаёт будущие и отсоединяет
+// старые. Отсоединённые секции остаются отдельными таблицами <таблица>_pYYYY_MM —
+// их выгрузка и удаление остаются за администратором.
+type Partitioner struct {
+	pool *pgxpool.Pool
+}
+
+func NewPartitioner(pool *pgxpool.Pool) *Partitioner {
+	return &Partitioner{pool: pool}
+}
+
+// monthStart — начало месяца t в UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnsurePartitions создаёт секции от текущего месяца на ahead месяцев вперёд.
+// Возвращает число созданных секций orders.
+func (p *Partitioner) EnsurePartitions(ctx context.Context, now time.Time, ahead int) (int, error) {
+	created := 0
+	for m := monthStart(now); !m.After(monthStart(now).AddDate(0, ahead, 0)); m = m.AddDate(0, 1, 0) {
+		ok, err := p.createMonth(ctx, m)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+func (p *Partitioner) createMonth(ctx context.Context, m time.Time) (bool, error) {
+	var exists bool
+	name := "orders_p" + m.Format(partitionLayout)
+	if err := p.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_class WHERE relname = $1 AND relkind = 'r')`, name).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	// все четыре секции месяца — одной транзакцией: строки дочерних таблиц не должны
+	// оказаться в default-секции, пока у orders своя секция уже есть
+	var moved int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+		if moved, err = p.takeFromDefault(ctx, tx, m); err != nil {
+			return err
+		}
+		for _, t := range partitionedTables {
+			_, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+				pgx.Identifier{t + "_p" + m.Format(partitionLayout)}.Sanitize(), pgx.Identifier{t}.Sanitize(),
+				m.Format(time.RFC3339), m.AddDate(0, 1, 0).Format(time.RFC3339)))
+			if err != nil {
+				return fmt.Errorf("create partition %s_p%s: %w", t, m.Format(partitionLayout), err)
+			}
+		}
+		// orders первой: на её строки ссылаются внешние ключи остальных
+		for _, t := range partitionedTables {
+			if _, err := tx.Exec(ctx, `INSERT INTO `+pgx.Identifier{t}.Sanitize()+` SELECT * FROM `+movedTable(t)); err != nil {
+				return fmt.Errorf("return %s rows from default partition: %w", t, err)
+			}
+		}
+		return nil
+	})
+	if err == nil && moved > 0 {
+		log.Printf("[partitions] orders_p%s: moved %d orders out of the default partition", m.Format(partitionLayout), moved)
+	}
+	return err == nil, err
+}
+
+func movedTable(t string) string { return pgx.Identifier{"moved_" + t}.Sanitize() }
+
+// takeFromDefault убирает строки месяца m из default-секций во временные таблицы:
+// пока default держит строки диапазона, CREATE ... PARTITION OF для него падает
+// (туда попадают заказы, пришедшие раньше, чем секция была создана, или с датой
+// дальше PARTITION_PRECREATE_MONTHS). Default-секции блокируются до конца
+// транзакции, чтобы новые строки месяца не попали в них между переносом и
+// созданием секции. Дочерние строки забираются до orders, иначе их удалил бы
+// каскад. Возвращает число перенесённых заказов.
+func (p *Partitioner) takeFromDefault(ctx context.Context, tx pgx.Tx, m time.Time) (int64, error) {
+	defaults := make([]string, len(partitionedTables))
+	for i, t := range partitionedTables {
+		defaults[i] = pgx.Identifier{t + "_pdefault"}.Sanitize()
+	}
+	if _, err := tx.Exec(ctx, `LOCK TABLE `+strings.Join(defaults, ", ")+` IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return 0, fmt.Errorf("lock default partitions: %w", err)
+	}
+	var orders int64
+	for i := len(partitionedTables) - 1; i >= 0; i-- {
+		t := partitionedTables[i]
+		if _, err := tx.Exec(ctx, `CREATE TEMP TABLE `+movedTable(t)+` (LIKE `+pgx.Identifier{t}.Sanitize()+`) ON COMMIT DROP`); err != nil {
+			return 0, fmt.Errorf("move %s rows from default partition: %w", t, err)
+		}
+		tag, err := tx.Exec(ctx, `
+WITH moved AS (DELETE FROM `+defaults[i]+` WHERE date_created >= $1 AND date_created < $2 RETURNING *)
+INSERT INTO `+movedTable(t)+` SELECT * FROM moved`, m, m.AddDate(0, 1, 0))
+		if err != nil {
+			return 0, fmt.Errorf("move %s rows from default partition: %w", t, err)
+		}
+		if t == "orders" {
+			orders = tag.RowsAffected()
+		}
+	}
+	return orders, nil
+}
+
+// DetachBefore отсоединяет секции месяцев, закончившихся до before, и удаляет
+// их заказы из order_keys. Возвращает имена отсоединённых секций orders.
+func (p *Partitioner) DetachBefore(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := p.pool.Query(ctx, `
+SELECT c.relname
+FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'orders'::regclass AND c.relname ~ '^orders_p[0-9]{4}_[0-9]{2}$'
+ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+	var detached []string
+	for _, name := range names {
+		m, err := time.Parse(partitionLayout, name[len("orders_p"):])
+		if err != nil || m.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+		if err := p.detachMonth(ctx, m); err != nil {
+			return detached, err
+		}
+		detached = append(detached, name)
+	}
+	return detached, nil
+}
+
+// detachMonth отсоединяет сначала дочерние секции и снимает с них внешние ключи —
+// иначе Postgres не даст отсоединить секцию orders, на строки которой они ссылаются.
+func (p *Partitioner) detachMonth(ctx context.Context, m time.Time) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		for i := len(partitionedTables) - 1; i >= 0; i-- {
+			t := partitionedTables[i]
+			part := pgx.Identifier{t + "_p" + m.Format(partitionLayout)}.Sanitize()
+			if _, err := tx.Exec(ctx, `ALTER TABLE `+pgx.Identifier{t}.Sanitize()+` DETACH PARTITION `+part); err != nil {
+				return fmt.Errorf("detach %s: %w", part, err)
+			}
+			if t == "orders" {
+				continue
+			}
+			rows, err := tx.Query(ctx, `SELECT conname FROM pg_constraint WHERE conrelid = $1::regclass AND contype = 'f'`, part)
+			if err != nil {
+				return fmt.Errorf("foreign keys of %s: %w", part, err)
+			}
+			fks, err := pgx.CollectRows(rows, pgx.RowTo[string])
+			if err != nil {
+				return fmt.Errorf("foreign keys of %s: %w", part, err)
+			}
+			for _, fk := range fks {
+				if _, err := tx.Exec(ctx, `ALTER TABLE `+part+` DROP CONSTRAINT `+pgx.Identifier{fk}.Sanitize()); err != nil {
+					return fmt.Errorf("drop %s on %s: %w", fk, part, err)
+				}
+			}
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM order_keys WHERE date_created >= $1 AND date_created < $2`, m, m.AddDate(0, 1, 0))
+		if err != nil {
+			return fmt.Errorf("delete order keys: %w", err)
+		}
+		return nil
+	})
+}
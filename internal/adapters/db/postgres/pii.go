@@ -0,0 +1,332 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/fieldcrypt"
+)
+
+// Шифруются контактные поля Delivery — и в deliveries, и внутри orders.raw_json.
+// Индекс, город и регион остаются открытыми: по ним строится аналитика.
+// Событие в outbox хранится с теми же зашифрованными полями, что и raw_json, и
+// расшифровывается релеем перед публикацией: внешние потребители получают открытые.
+func piiFields(d *domain.Delivery) map[string]*string {
+	return map[string]*string{"name": &d.Name, "phone": &d.Phone, "address": &d.Address, "email": &d.Email}
+}
+
+// sealOrder шифрует контактные поля новым ключом данных. Без keyring заказ
+// возвращается как есть, kid и wrapped — nil.
+func (r *OrderRepo) sealOrder(o domain.Order) (domain.Order, *string, []byte, error) {
+	if r.keys == nil {
+		return o, nil, nil, nil
+	}
+	dek, err := fieldcrypt.NewDEK()
+	if err != nil {
+		return o, nil, nil, err
+	}
+	for name, v := range piiFields(&o.Delivery) {
+		if *v, err = fieldcrypt.Seal(dek, *v, o.OrderUID+"/"+name); err != nil {
+			return o, nil, nil, fmt.Errorf("seal %s: %w", name, err)
+		}
+	}
+	kid, wrapped, err := r.keys.Wrap(dek, o.OrderUID)
+	if err != nil {
+		return o, nil, nil, fmt.Errorf("wrap dek: %w", err)
+	}
+	return o, &kid, wrapped, nil
+}
+
+// openOrder расшифровывает поля на месте; kid == nil — запись сохранена открытой.
+func (r *OrderRepo) openOrder(o *domain.Order, kid *string, wrapped []byte) error {
+	return openDelivery(r.keys, o, kid, wrapped)
+}
+
+func openDelivery(keys *fieldcrypt.Keyring, o *domain.Order, kid *string, wrapped []byte) error {
+	if kid == nil {
+		return nil
+	}
+	if keys == nil {
+		return fmt.Errorf("order %s is encrypted with key %q but no keyring is configured", o.OrderUID, *kid)
+	}
+	dek, err := keys.Unwrap(*kid, wrapped, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", o.OrderUID, err)
+	}
+	for name, v := range piiFields(&o.Delivery) {
+		if *v, err = fieldcrypt.Open(dek, *v, o.OrderUID+"/"+name); err != nil {
+			return fmt.Errorf("order %s %s: %w", o.OrderUID, name, err)
+		}
+	}
+	return nil
+}
+
+// emailIndex — слепой индекс email для поиска по зашифрованным deliveries;
+// nil без keyring или без ключа index (тогда email ищется по открытому значению).
+func (r *OrderRepo) emailIndex(email string) []byte {
+	email = domain.Subject{Email: email}.Normalize().Email
+	if r.keys == nil || email == "" {
+		return nil
+	}
+	return r.keys.BlindIndex(email)
+}
+
+// rawDigest — отпечаток открытого raw_json: шифртекст каждый раз разный, а
+// повторная доставка того же заказа не должна считаться изменением. С keyring
+// это HMAC ключом index, иначе по отпечатку можно было бы подбирать контакты;
+// без keyring raw_json и так открыт, и хватает SHA-256.
+func (r *OrderRepo) rawDigest(raw []byte) []byte {
+	if r.keys != nil {
+		if d := r.keys.Digest(raw); d != nil {
+			return d
+		}
+	}
+	d := sha256.Sum256(raw)
+	return d[:]
+}
+
+// errBadRow — заказ нельзя перешифровать: его ключа нет в keyring, шифртекст
+// не расшифровывается или raw_json не разбирается.
+var errBadRow = errors.New("cannot reencrypt")
+
+func badRow(err error) error { return fmt.Errorf("%w: %w", errBadRow, err) }
+
+type pendingRow struct {
+	uid     string
+	created time.Time
+	raw     []byte
+	kid     *string
+	wrapped []byte
+}
+
+// ReencryptBatch приводит до limit заказов к активному ключу keyring:
+// открытые (сохранённые до включения шифрования) шифрует, а у зашифрованных
+// другим ключом переупаковывает ключ данных. Заодно заполняет слепой индекс email,
+// если ключ index добавлен позже шифрования. Архивные заказы обрабатываются после
+// рабочих: пока они под старым ключом, удалять его из keyring нельзя.
+// События outbox не перешифровываются: релей удаляет их сразу после публикации,
+// но при выключенном релее старый ключ нужен и им.
+// Перед проходом keyring перечитывается, так что смена active в файле
+// подхватывается без рестарта.
+//
+// Заказ, который перешифровать нельзя (errBadRow), не останавливает проход:
+// он помечается в pii_failed_kek активным ключом и пропускается, пока active не
+// сменится. Чтобы повторить раньше (например, вернув удалённый ключ в keyring),
+// pii_failed_kek нужно обнулить. failed — сколько таких заказов в этом батче.
+func (r *OrderRepo) ReencryptBatch(ctx context.Context, limit int) (done, failed int, err error) {
+	if r.keys == nil {
+		return 0, 0, errors.New("no keyring configured")
+	}
+	if _, err := r.keys.Reload(); err != nil {
+		return 0, 0, err
+	}
+	active := r.keys.Active()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	pending, err := selectPending(ctx, tx, `
+SELECT o.order_uid, o.date_created, o.raw_json, o.pii_kek, o.pii_dek
+FROM orders o LEFT JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+WHERE (o.pii_kek IS DISTINCT FROM $1 OR ($3 AND d.email_idx IS NULL AND d.email <> ''))
+  AND o.pii_failed_kek IS DISTINCT FROM $1
+ORDER BY o.order_uid
+LIMIT $2
+FOR UPDATE OF o SKIP LOCKED
+`, active, limit, r.keys.HasIndex())
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range pending {
+		if p.kid != nil {
+			err = r.rekey(ctx, tx, p, active)
+		} else {
+			err = r.encryptPlain(ctx, tx, p)
+		}
+		if errors.Is(err, errBadRow) {
+			log.Printf("[pii] skip order %s until the active key changes: %v", p.uid, err)
+			failed++
+			_, err = tx.Exec(ctx, `UPDATE orders SET pii_failed_kek=$2 WHERE order_uid=$1 AND date_created=$3`, p.uid, active, p.created)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var archived []pendingRow
+	if len(pending) < limit {
+		archived, err = selectPending(ctx, tx, `
+SELECT order_uid, date_created, raw_json, pii_kek, pii_dek
+FROM orders_archive
+WHERE (pii_kek IS DISTINCT FROM $1
+       OR ($3 AND email_idx IS NULL AND erased_at IS NULL AND raw_json#>>'{delivery,email}' <> ''))
+  AND pii_failed_kek IS DISTINCT FROM $1
+ORDER BY order_uid
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`, active, limit-len(pending), r.keys.HasIndex())
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, p := range archived {
+		err := r.rekeyArchived(ctx, tx, p, active)
+		if errors.Is(err, errBadRow) {
+			log.Printf("[pii] skip archived order %s until the active key changes: %v", p.uid, err)
+			failed++
+			_, err = tx.Exec(ctx, `UPDATE orders_archive SET pii_failed_kek=$2 WHERE order_uid=$1`, p.uid, active)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(pending) + len(archived) - failed, failed, nil
+}
+
+func selectPending(ctx context.Context, tx pgx.Tx, q string, args ...any) ([]pendingRow, error) {
+	rows, err := tx.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select pending: %w", err)
+	}
+	pending, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingRow, error) {
+		var p pendingRow
+		err := row.Scan(&p.uid, &p.created, &p.raw, &p.kid, &p.wrapped)
+		return p, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select pending: %w", err)
+	}
+	return pending, nil
+}
+
+// rekey переупаковывает ключ данных активным ключом и проставляет слепой индекс email.
+func (r *OrderRepo) rekey(ctx context.Context, tx pgx.Tx, p pendingRow, active string) error {
+	if *p.kid != active {
+		dek, err := r.keys.Unwrap(*p.kid, p.wrapped, p.uid)
+		if err != nil {
+			return badRow(fmt.Errorf("order %s: %w", p.uid, err))
+		}
+		kid, wrapped, err := r.keys.Wrap(dek, p.uid)
+		if err != nil {
+			return fmt.Errorf("order %s: wrap dek: %w", p.uid, err)
+		}
+		if _, err = tx.Exec(ctx, `UPDATE orders SET pii_kek=$2, pii_dek=$3 WHERE order_uid=$1 AND date_created=$4`,
+			p.uid, kid, wrapped, p.created); err != nil {
+			return fmt.Errorf("rewrap %s: %w", p.uid, err)
+		}
+	}
+	if !r.keys.HasIndex() {
+		return nil
+	}
+	o, err := domain.DecodeOrderJSON(p.raw, false)
+	if err != nil {
+		return badRow(fmt.Errorf("order %s: unmarshal: %w", p.uid, err))
+	}
+	if err := r.openOrder(&o, p.kid, p.wrapped); err != nil {
+		return badRow(err)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE deliveries SET email_idx=$2 WHERE order_uid=$1 AND date_created=$3`,
+		p.uid, r.emailIndex(o.Delivery.Email), p.created); err != nil {
+		return fmt.Errorf("email index %s: %w", p.uid, err)
+	}
+	return nil
+}
+
+func (r *OrderRepo) encryptPlain(ctx context.Context, tx pgx.Tx, p pendingRow) error {
+	o, err := domain.DecodeOrderJSON(p.raw, false)
+	if err != nil {
+		return badRow(fmt.Errorf("order %s: unmarshal: %w", p.uid, err))
+	}
+	plain, err := o.RawJSON()
+	if err != nil {
+		return fmt.Errorf("order %s: marshal raw: %w", p.uid, err)
+	}
+	sealed, kid, wrapped, err := r.sealOrder(o)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", p.uid, err)
+	}
+	raw, err := sealed.RawJSON()
+	if err != nil {
+		return fmt.Errorf("order %s: marshal raw: %w", p.uid, err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE orders SET raw_json=$2, raw_digest=$3, pii_kek=$4, pii_dek=$5 WHERE order_uid=$1 AND date_created=$6`,
+		p.uid, raw, r.rawDigest(plain), kid, wrapped, p.created)
+	if err != nil {
+		return fmt.Errorf("encrypt %s: %w", p.uid, err)
+	}
+	d := sealed.Delivery
+	_, err = tx.Exec(ctx, `UPDATE deliveries SET name=$2, phone=$3, address=$4, email=$5, email_idx=$6 WHERE order_uid=$1 AND date_created=$7`,
+		p.uid, d.Name, d.Phone, d.Address, d.Email, r.emailIndex(o.Delivery.Email), p.created)
+	if err != nil {
+		return fmt.Errorf("encrypt deliveries %s: %w", p.uid, err)
+	}
+	return nil
+}
+
+// rekeyArchived — то же для orders_archive, где заказ хранится только в raw_json.
+func (r *OrderRepo) rekeyArchived(ctx context.Context, tx pgx.Tx, p pendingRow, active string) error {
+	o, err := domain.DecodeOrderJSON(p.raw, false)
+	if err != nil {
+		return badRow(fmt.Errorf("archived order %s: unmarshal: %w", p.uid, err))
+	}
+	if err := r.openOrder(&o, p.kid, p.wrapped); err != nil {
+		return badRow(err)
+	}
+	idx := r.emailIndex(o.Delivery.Email)
+	switch {
+	case p.kid == nil:
+		plain, err := o.RawJSON()
+		if err != nil {
+			return fmt.Errorf("archived order %s: marshal raw: %w", p.uid, err)
+		}
+		sealed, kid, wrapped, err := r.sealOrder(o)
+		if err != nil {
+			return fmt.Errorf("archived order %s: %w", p.uid, err)
+		}
+		raw, err := sealed.RawJSON()
+		if err != nil {
+			return fmt.Errorf("archived order %s: marshal raw: %w", p.uid, err)
+		}
+		_, err = tx.Exec(ctx, `UPDATE orders_archive SET raw_json=$2, raw_digest=$3, pii_kek=$4, pii_dek=$5, email_idx=$6 WHERE order_uid=$1`,
+			p.uid, raw, r.rawDigest(plain), kid, wrapped, idx)
+		if err != nil {
+			return fmt.Errorf("encrypt archived %s: %w", p.uid, err)
+		}
+	case *p.kid != active:
+		dek, err := r.keys.Unwrap(*p.kid, p.wrapped, p.uid)
+		if err != nil {
+			return badRow(fmt.Errorf("archived order %s: %w", p.uid, err))
+		}
+		kid, wrapped, err := r.keys.Wrap(dek, p.uid)
+		if err != nil {
+			return fmt.Errorf("archived order %s: wrap dek: %w", p.uid, err)
+		}
+		_, err = tx.Exec(ctx, `UPDATE orders_archive SET pii_kek=$2, pii_dek=$3, email_idx=$4 WHERE order_uid=$1`, p.uid, kid, wrapped, idx)
+		if err != nil {
+			return fmt.Errorf("rewrap archived %s: %w", p.uid, err)
+		}
+	default:
+		if _, err := tx.Exec(ctx, `UPDATE orders_archive SET email_idx=$2 WHERE order_uid=$1`, p.uid, idx); err != nil {
+			return fmt.Errorf("email index archived %s: %w", p.uid, err)
+		}
+	}
+	return nil
+}
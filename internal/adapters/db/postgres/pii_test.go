@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/fieldcrypt"
+)
+
+// testKeyring — keyring с ключами ids (ключ — id[0], повторённый) и ключом index.
+func testKeyring(t *testing.T, active string, ids ...string) *fieldcrypt.Keyring {
+	t.Helper()
+	keys := map[string]string{}
+	for _, id := range ids {
+		keys[id] = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id[:1]), fieldcrypt.KeySize))
+	}
+	data, err := json.Marshal(map[string]any{
+		"active": active,
+		"keys":   keys,
+		"index":  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("i"), fieldcrypt.KeySize)),
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	kr, err := fieldcrypt.LoadKeyring(path)
+	require.NoError(t, err)
+	return kr
+}
+
+func testRepo(t *testing.T, active string, ids ...string) *OrderRepo {
+	return NewOrderRepo(nil, WithKeyring(testKeyring(t, active, ids...)))
+}
+
+func piiOrder(uid string) domain.Order {
+	return domain.Order{OrderUID: uid, TrackNumber: "WBILMTESTTRACK", Entry: "WBIL",
+		DateCreated: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), Delivery: domain.Delivery{
+			Name: "Test Testov", Phone: "+79720000045", Address: "Ploshad Mira 15", Email: "test@gmail.com",
+		}}
+}
+
+func TestSealOpenOrder(t *testing.T) {
+	r := testRepo(t, "a1", "a1")
+	o := piiOrder("u1")
+
+	sealed, kid, wrapped, err := r.sealOrder(o)
+	require.NoError(t, err)
+	require.Equal(t, "a1", *kid)
+	for name, v := range piiFields(&sealed.Delivery) {
+		require.True(t, fieldcrypt.IsSealed(*v), name)
+	}
+	require.Equal(t, "+79720000045", o.Delivery.Phone, "input order is not modified")
+
+	opened := sealed
+	require.NoError(t, r.openOrder(&opened, kid, wrapped))
+	require.Equal(t, o.Delivery, opened.Delivery)
+
+	// шифртекст привязан к заказу: чужой order_uid не расшифровывается
+	moved := sealed
+	moved.OrderUID = "u2"
+	require.Error(t, r.openOrder(&moved, kid, wrapped))
+
+	// заказ, сохранённый до включения шифрования, читается как есть
+	plain := o
+	require.NoError(t, r.openOrder(&plain, nil, nil))
+	require.Equal(t, o.Delivery, plain.Delivery)
+}
+
+func TestOpenOrder_UnknownKey(t *testing.T) {
+	sealed, kid, wrapped, err := testRepo(t, "a1", "a1").sealOrder(piiOrder("u1"))
+	require.NoError(t, err)
+
+	err = testRepo(t, "b2", "b2").openOrder(&sealed, kid, wrapped)
+	require.ErrorIs(t, err, fieldcrypt.ErrUnknownKey)
+	require.ErrorIs(t, badRow(err), errBadRow)
+
+	err = NewOrderRepo(nil).openOrder(&sealed, kid, wrapped)
+	require.ErrorContains(t, err, "no keyring is configured")
+}
+
+func TestRawDigest_Keyed(t *testing.T) {
+	raw := []byte(`{"order_uid":"u1"}`)
+	plain := sha256.Sum256(raw)
+
+	require.Equal(t, plain[:], NewOrderRepo(nil).rawDigest(raw))
+	r := testRepo(t, "a1", "a1")
+	require.NotEqual(t, plain[:], r.rawDigest(raw), "with a keyring the digest is an HMAC")
+	require.Equal(t, r.rawDigest(raw), r.rawDigest(raw))
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ShardDirectory — справочник order_uid → шард в таблице order_shards основной базы (DB_URL).
+type ShardDirectory struct {
+	pool *pgxpool.Pool
+}
+
+func NewShardDirectory(pool *pgxpool.Pool) *ShardDirectory {
+	return &ShardDirectory{pool: pool}
+}
+
+func (d *ShardDirectory) Lookup(orderUID string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var shard string
+	err := d.pool.QueryRow(ctx, `SELECT shard FROM order_shards WHERE order_uid=$1`, orderUID).Scan(&shard)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return shard, true, nil
+}
+
+// Assign — вставка без перезаписи: RETURNING отдаёт уже закреплённый шард, если он есть.
+func (d *ShardDirectory) Assign(orderUID, shard string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var pinned string
+	err := d.pool.QueryRow(ctx, `
+INSERT INTO order_shards (order_uid, shard) VALUES ($1, $2)
+ON CONFLICT (order_uid) DO UPDATE SET order_uid = EXCLUDED.order_uid
+RETURNING shard
+`, orderUID, shard).Scan(&pinned)
+	return pinned, err
+}
@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// subjectWhere — заказы субъекта: по customer_id, по открытому email (записи без
+// шифрования) или по слепому индексу email (зашифрованные записи).
+const subjectWhere = `
+WHERE ($1 <> '' AND o.customer_id = $1)
+   OR ($2 <> '' AND lower(d.email) = $2)
+   OR ($3::bytea IS NOT NULL AND d.email_idx = $3)`
+
+// archiveSubjectWhere — то же для orders_archive: открытый email берётся из raw_json.
+const archiveSubjectWhere = `
+WHERE ($1 <> '' AND a.customer_id = $1)
+   OR ($2 <> '' AND lower(a.raw_json#>>'{delivery,email}') = $2)
+   OR ($3::bytea IS NOT NULL AND a.email_idx = $3)`
+
+func (r *OrderRepo) subjectArgs(s domain.Subject) ([]any, error) {
+	s = s.Normalize()
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	// без ключа index зашифрованные email не найти — лучше отказать, чем молча пропустить заказы
+	if s.Email != "" && r.keys != nil && !r.keys.HasIndex() {
+		return nil, errors.New("email lookup over encrypted deliveries needs an index key in the keyring")
+	}
+	return []any{s.CustomerID, s.Email, r.emailIndex(s.Email)}, nil
+}
+
+// FindBySubject — все заказы субъекта, включая архивные, расшифрованные, от старых к новым.
+func (r *OrderRepo) FindBySubject(s domain.Subject) ([]domain.Order, error) {
+	args, err := r.subjectArgs(s)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rows, err := r.pool.Query(ctx, `
+SELECT raw_json, updated_at, pii_kek, pii_dek FROM (
+    SELECT o.raw_json, o.updated_at, o.pii_kek, o.pii_dek, o.date_created
+    FROM orders o LEFT JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created`+subjectWhere+`
+    UNION ALL
+    SELECT a.raw_json, a.updated_at, a.pii_kek, a.pii_dek, a.date_created
+    FROM orders_archive a`+archiveSubjectWhere+`
+) s
+ORDER BY date_created`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanOrders(rows)
+}
+
+// IsErased — заказ обезличен в orders или в orders_archive.
+func (r *OrderRepo) IsErased(orderUID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var erased bool
+	err := r.pool.QueryRow(ctx, `
+SELECT EXISTS (SELECT 1 FROM orders
+               WHERE order_uid=$1 AND erased_at IS NOT NULL
+                 AND date_created = (SELECT date_created FROM order_keys WHERE order_uid=$1))
+    OR EXISTS (SELECT 1 FROM orders_archive WHERE order_uid=$1 AND erased_at IS NOT NULL)
+`, orderUID).Scan(&erased)
+	if err != nil {
+		return false, fmt.Errorf("check erased: %w", err)
+	}
+	return erased, nil
+}
+
+// EraseSubject обезличивает заказы субъекта в orders (колонки и raw_json), deliveries,
+// orders_archive и в ещё не отправленных событиях outbox, помечает их erased_at (повторный приём из брокера
+// не вернёт данные) и пишет запись в журнал erasures — всё в одной транзакции.
+// Возвращает запись журнала и обезличенные заказы.
+func (r *OrderRepo) EraseSubject(s domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error) {
+	args, err := r.subjectArgs(s)
+	if err != nil {
+		return e, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return e, nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	rows, err := tx.Query(ctx, `
+SELECT o.raw_json, o.updated_at, o.pii_kek, o.pii_dek
+FROM orders o LEFT JOIN deliveries d ON d.order_uid = o.order_uid AND d.date_created = o.date_created`+subjectWhere+`
+ORDER BY o.order_uid
+FOR UPDATE OF o`, args...)
+	if err != nil {
+		return e, nil, fmt.Errorf("select subject orders: %w", err)
+	}
+	orders, err := r.scanOrders(rows)
+	if err != nil {
+		return e, nil, err
+	}
+
+	e.OrderUIDs = make([]string, 0, len(orders))
+	for i, o := range orders {
+		anon := o.Anonymize(e.Pseudonym)
+		anon.UpdatedAt = e.ErasedAt
+		if err := r.eraseOrder(ctx, tx, anon, e.ErasedAt); err != nil {
+			return e, nil, err
+		}
+		orders[i] = anon
+		e.OrderUIDs = append(e.OrderUIDs, o.OrderUID)
+	}
+
+	rows, err = tx.Query(ctx, `
+SELECT a.raw_json, a.updated_at, a.pii_kek, a.pii_dek
+FROM orders_archive a`+archiveSubjectWhere+`
+ORDER BY a.order_uid
+FOR UPDATE`, args...)
+	if err != nil {
+		return e, nil, fmt.Errorf("select archived subject orders: %w", err)
+	}
+	archived, err := r.scanOrders(rows)
+	if err != nil {
+		return e, nil, err
+	}
+	for _, o := range archived {
+		anon := o.Anonymize(e.Pseudonym)
+		anon.UpdatedAt = e.ErasedAt
+		if err := r.eraseArchived(ctx, tx, anon, e.ErasedAt); err != nil {
+			return e, nil, err
+		}
+		orders = append(orders, anon)
+		if !slices.Contains(e.OrderUIDs, o.OrderUID) {
+			e.OrderUIDs = append(e.OrderUIDs, o.OrderUID)
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+INSERT INTO erasures (subject_digest, pseudonym, order_uids, requested_by, request_id, reason, erased_at)
+VALUES ($1,$2,$3,$4,$5,$6,$7)
+RETURNING id
+`, e.SubjectDigest, e.Pseudonym, e.OrderUIDs, e.RequestedBy, e.RequestID, e.Reason, e.ErasedAt).Scan(&e.ID)
+	if err != nil {
+		return e, nil, fmt.Errorf("insert erasure: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return e, nil, fmt.Errorf("commit: %w", err)
+	}
+	return e, orders, nil
+}
+
+func (r *OrderRepo) eraseOrder(ctx context.Context, tx pgx.Tx, anon domain.Order, at time.Time) error {
+	plain, err := anon.RawJSON()
+	if err != nil {
+		return fmt.Errorf("marshal raw: %w", err)
+	}
+	// обезличенный заказ тоже проходит через sealOrder: kek остаётся активным,
+	// и фоновая перешифровка эти записи не трогает
+	stored, kid, wrapped, err := r.sealOrder(anon)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	raw, err := stored.RawJSON()
+	if err != nil {
+		return fmt.Errorf("marshal raw: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+UPDATE orders SET customer_id=$2, raw_json=$3, raw_digest=$4, pii_kek=$5, pii_dek=$6, updated_at=$7, erased_at=$7
+WHERE order_uid=$1 AND date_created=$8
+`, anon.OrderUID, anon.CustomerID, raw, r.rawDigest(plain), kid, wrapped, at, anon.DateCreated)
+	if err != nil {
+		return fmt.Errorf("erase order %s: %w", anon.OrderUID, err)
+	}
+	_, err = tx.Exec(ctx, `
+UPDATE deliveries SET name='', phone='', zip='', address='', email='', email_idx=NULL
+WHERE order_uid=$1 AND date_created=$2
+`, anon.OrderUID, anon.DateCreated)
+	if err != nil {
+		return fmt.Errorf("erase delivery %s: %w", anon.OrderUID, err)
+	}
+	return eraseHistory(ctx, tx, anon.OrderUID, plain)
+}
+
+// eraseHistory — неотправленные события получают обезличенный заказ (отправленные
+// релей уже удалил из outbox).
+func eraseHistory(ctx context.Context, tx pgx.Tx, uid string, plain []byte) error {
+	_, err := tx.Exec(ctx, `
+UPDATE outbox SET payload = jsonb_set(payload, '{order}', $2::jsonb), pii_kek = NULL, pii_dek = NULL
+WHERE aggregate_id=$1
+`, uid, plain)
+	if err != nil {
+		return fmt.Errorf("erase outbox %s: %w", uid, err)
+	}
+	return nil
+}
+
+func (r *OrderRepo) eraseArchived(ctx context.Context, tx pgx.Tx, anon domain.Order, at time.Time) error {
+	plain, err := anon.RawJSON()
+	if err != nil {
+		return fmt.Errorf("marshal raw: %w", err)
+	}
+	stored, kid, wrapped, err := r.sealOrder(anon)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	raw, err := stored.RawJSON()
+	if err != nil {
+		return fmt.Errorf("marshal raw: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+UPDATE orders_archive SET customer_id=$2, raw_json=$3, raw_digest=$4, pii_kek=$5, pii_dek=$6, email_idx=NULL,
+                          updated_at=$7, erased_at=$7
+WHERE order_uid=$1
+`, anon.OrderUID, anon.CustomerID, raw, r.rawDigest(plain), kid, wrapped, at)
+	if err != nil {
+		return fmt.Errorf("erase archived order %s: %w", anon.OrderUID, err)
+	}
+	return eraseHistory(ctx, tx, anon.OrderUID, plain)
+}
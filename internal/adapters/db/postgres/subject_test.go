@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// requireNoPII проверяет, что в выборке query(uid) нет открытых контактов.
+func requireNoPII(t *testing.T, r *OrderRepo, query, uid string) {
+	t.Helper()
+	rows, err := r.pool.Query(context.Background(), query, uid)
+	require.NoError(t, err)
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var text string
+		require.NoError(t, rows.Scan(&text))
+		for _, v := range piiValues {
+			require.NotContains(t, text, v, "%s: %s", query, uid)
+		}
+		n++
+	}
+	require.NoError(t, rows.Err())
+	require.NotZero(t, n, "%s: %s", query, uid)
+}
+
+func TestOrderRepo_EraseSubject(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	r := NewOrderRepo(pool)
+
+	// u1 в рабочих таблицах, u2 уйдёт в архив, u3 — заказ другого покупателя
+	u1, u2 := piiOrder("u1"), piiOrder("u2")
+	u1.CustomerID, u2.CustomerID = "c1", "c1"
+	u2.DateCreated = time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	u3 := piiOrder("u3")
+	u3.CustomerID = "c2"
+	u3.Delivery.Email = "other@gmail.com"
+	for _, o := range []domain.Order{u1, u2, u3} {
+		_, err := r.UpsertOrder(o)
+		require.NoError(t, err)
+	}
+	moved, err := NewTableArchiver(pool).MoveBatch(ctx, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, moved)
+
+	found, err := r.FindBySubject(domain.Subject{Email: "TEST@gmail.com"})
+	require.NoError(t, err)
+	require.Len(t, found, 3, "email is shared by all three orders")
+	found, err = r.FindBySubject(domain.Subject{CustomerID: "c1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"u2", "u1"}, []string{found[0].OrderUID, found[1].OrderUID})
+	require.Equal(t, u1.Delivery, found[1].Delivery)
+
+	at := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	rec, anon, err := r.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{
+		SubjectDigest: "digest", Pseudonym: "anon-1", RequestedBy: "ops", RequestID: "req-1", ErasedAt: at,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, rec.ID)
+	require.ElementsMatch(t, []string{"u1", "u2"}, rec.OrderUIDs)
+	require.Len(t, anon, 2)
+
+	// deliveries
+	var name, phone, address, email string
+	var emailIdx []byte
+	require.NoError(t, pool.QueryRow(ctx, `SELECT name, phone, address, email, email_idx FROM deliveries WHERE order_uid='u1'`).
+		Scan(&name, &phone, &address, &email, &emailIdx))
+	require.Empty(t, name+phone+address+email)
+	require.Nil(t, emailIdx)
+
+	// orders.raw_json и orders_archive
+	requireNoPII(t, r, `SELECT raw_json::text FROM orders WHERE order_uid=$1 AND erased_at IS NOT NULL AND customer_id='anon-1'`, "u1")
+	requireNoPII(t, r, `SELECT raw_json::text FROM orders_archive WHERE order_uid=$1 AND erased_at IS NOT NULL AND customer_id='anon-1'`, "u2")
+
+	// неотправленные события outbox
+	for _, uid := range []string{"u1", "u2"} {
+		requireNoPII(t, r, `SELECT payload::text FROM outbox WHERE aggregate_id=$1 AND pii_kek IS NULL`, uid)
+		erased, err := r.IsErased(uid)
+		require.NoError(t, err)
+		require.True(t, erased, uid)
+	}
+
+	// журнал erasures
+	var (
+		pseudonym, by string
+		uids          []string
+		erasedAt      time.Time
+	)
+	require.NoError(t, pool.QueryRow(ctx, `SELECT pseudonym, order_uids, requested_by, erased_at FROM erasures WHERE id=$1`, rec.ID).
+		Scan(&pseudonym, &uids, &by, &erasedAt))
+	require.Equal(t, "anon-1", pseudonym)
+	require.ElementsMatch(t, []string{"u1", "u2"}, uids)
+	require.Equal(t, "ops", by)
+	require.True(t, at.Equal(erasedAt))
+
+	// чужой заказ не тронут
+	o, ok, err := r.GetByID("u3")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, u3.Delivery, o.Delivery)
+	erased, err := r.IsErased("u3")
+	require.NoError(t, err)
+	require.False(t, erased)
+
+	found, err = r.FindBySubject(domain.Subject{CustomerID: "c1"})
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	// повтор безопасен: заказов не осталось, пишется пустая запись журнала
+	again, _, err := r.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{Pseudonym: "anon-2", ErasedAt: at})
+	require.NoError(t, err)
+	require.Empty(t, again.OrderUIDs)
+}
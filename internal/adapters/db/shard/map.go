@@ -0,0 +1,83 @@
+package shard
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Map — какой шард хранит заказы с данным ShardKey.
+type Map struct {
+	keys map[string]string
+	// def — шард для ключей, которых нет в keys; "" — такие заказы не принимаются.
+	def string
+}
+
+// ParseMap разбирает "ключи=шард,...". Ключи — значение ShardKey, диапазон
+// целых "0-4" или "*" для всех остальных. shards — известные имена шардов.
+//
+//	0-4=s0,5-9=s1,*=s0
+func ParseMap(s string, shards []string) (Map, error) {
+	m := Map{keys: map[string]string{}}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		keys, name, ok := strings.Cut(part, "=")
+		keys, name = strings.TrimSpace(keys), strings.TrimSpace(name)
+		if !ok || keys == "" {
+			return Map{}, fmt.Errorf("shard map %q: want keys=shard", part)
+		}
+		if !slices.Contains(shards, name) {
+			return Map{}, fmt.Errorf("shard map %q: unknown shard %q", part, name)
+		}
+		if keys == "*" {
+			if m.def != "" {
+				return Map{}, fmt.Errorf("shard map %q: duplicate *", part)
+			}
+			m.def = name
+			continue
+		}
+		expanded, err := expandKeys(keys)
+		if err != nil {
+			return Map{}, fmt.Errorf("shard map %q: %w", part, err)
+		}
+		for _, k := range expanded {
+			if prev, dup := m.keys[k]; dup {
+				return Map{}, fmt.Errorf("shard map: key %q mapped to both %s and %s", k, prev, name)
+			}
+			m.keys[k] = name
+		}
+	}
+	if len(m.keys) == 0 && m.def == "" {
+		return Map{}, fmt.Errorf("shard map is empty")
+	}
+	return m, nil
+}
+
+func expandKeys(keys string) ([]string, error) {
+	lo, hi, isRange := strings.Cut(keys, "-")
+	if !isRange {
+		return []string{keys}, nil
+	}
+	from, err1 := strconv.Atoi(lo)
+	to, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil || from > to || to-from > 10000 {
+		return nil, fmt.Errorf("bad key range %q", keys)
+	}
+	out := make([]string, 0, to-from+1)
+	for k := from; k <= to; k++ {
+		out = append(out, strconv.Itoa(k))
+	}
+	return out, nil
+}
+
+// Shard — шард для ключа; false — ключ не описан и шарда по умолчанию нет.
+func (m Map) Shard(key string) (string, bool) {
+	if name, ok := m.keys[strings.TrimSpace(key)]; ok {
+		return name, true
+	}
+	return m.def, m.def != ""
+}
@@ -0,0 +1,237 @@
+package shard
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+// ErrUnroutable — у заказа ShardKey, которого нет в карте шардов, и шарда по умолчанию нет.
+// Повторная доставка даст тот же результат, поэтому это ErrInvalidOrder: сообщение
+// снимается с доставки, а не повторяется.
+var ErrUnroutable = fmt.Errorf("%w: no shard for shard key", domain.ErrInvalidOrder)
+
+// Store — хранилище одного шарда.
+type Store interface {
+	domain.OrderRepository
+	FindBySubject(s domain.Subject) ([]domain.Order, error)
+	EraseSubject(s domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error)
+	IsErased(orderUID string) (bool, error)
+}
+
+// Directory — справочник order_uid → шард.
+type Directory interface {
+	Lookup(orderUID string) (shard string, ok bool, err error)
+	// Assign закрепляет заказ за шардом, если он ещё не закреплён, и возвращает
+	// шард, за которым заказ закреплён в итоге.
+	Assign(orderUID, shard string) (string, error)
+}
+
+// Repo — domain.OrderRepository поверх нескольких шардов. Новый заказ пишется
+// в шард по ShardKey и закрепляется за ним в Directory; дальше он остаётся там,
+// даже если ShardKey в повторной доставке другой — иначе заказ раздвоился бы.
+// GetByID идёт в шард из Directory. Заказы, которых в нём нет (записанные до
+// включения шардирования), с WithLegacyLookup ищутся во всех шардах и
+// дописываются в Directory; без него такой заказ считается несуществующим.
+// LoadAll и Search опрашивают все шарды параллельно и сливают результаты.
+type Repo struct {
+	shards map[string]Store
+	names  []string
+	keys   Map
+	dir    Directory
+	legacy bool
+}
+
+type Option func(*Repo)
+
+// WithLegacyLookup включает поиск по всем шардам заказов, которых нет в Directory.
+// Нужен, пока Directory не заполнен для заказов, записанных до шардирования: каждый
+// промах (в том числе перебор несуществующих id) стоит запроса в каждый шард.
+func WithLegacyLookup() Option {
+	return func(r *Repo) { r.legacy = true }
+}
+
+func New(shards map[string]Store, keys Map, dir Directory, opts ...Option) *Repo {
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	r := &Repo{shards: shards, names: names, keys: keys, dir: dir}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Repo) UpsertOrder(o domain.Order) (domain.UpsertResult, error) {
+	name, ok := r.keys.Shard(o.ShardKey)
+	if !ok {
+		metrics.ShardUnroutable.Inc()
+		return domain.UpsertResult{}, fmt.Errorf("%w %q (order %s)", ErrUnroutable, o.ShardKey, o.OrderUID)
+	}
+	pinned, err := r.dir.Assign(o.OrderUID, name)
+	if err != nil {
+		return domain.UpsertResult{}, fmt.Errorf("shard directory: %w", err)
+	}
+	s, ok := r.shards[pinned]
+	if !ok {
+		return domain.UpsertResult{}, fmt.Errorf("order %s is pinned to shard %q which is not configured", o.OrderUID, pinned)
+	}
+	return s.UpsertOrder(o)
+}
+
+func (r *Repo) GetByID(id string) (domain.Order, bool, error) {
+	name, ok, err := r.dir.Lookup(id)
+	if err != nil {
+		return domain.Order{}, false, fmt.Errorf("shard directory: %w", err)
+	}
+	if s, known := r.shards[name]; ok && known {
+		return s.GetByID(id)
+	}
+	if !r.legacy {
+		return domain.Order{}, false, nil
+	}
+
+	type hit struct {
+		o  domain.Order
+		ok bool
+	}
+	res, err := fanOut(r, func(s Store) (hit, error) {
+		o, ok, err := s.GetByID(id)
+		return hit{o, ok}, err
+	})
+	if err != nil {
+		return domain.Order{}, false, err
+	}
+	for i, h := range res {
+		if !h.ok {
+			continue
+		}
+		if _, err := r.dir.Assign(id, r.names[i]); err != nil {
+			log.Printf("[shard] backfill directory for %s: %v", id, err)
+		}
+		return h.o, true, nil
+	}
+	return domain.Order{}, false, nil
+}
+
+func (r *Repo) LoadAll(limit int) ([]domain.Order, error) {
+	res, err := fanOut(r, func(s Store) ([]domain.Order, error) { return s.LoadAll(limit) })
+	if err != nil {
+		return nil, err
+	}
+	return mergeNewest(res, limit), nil
+}
+
+func (r *Repo) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	res, err := fanOut(r, func(s Store) ([]domain.Order, error) { return s.Search(f) })
+	if err != nil {
+		return nil, err
+	}
+	return mergeNewest(res, f.Limit), nil
+}
+
+// FindBySubject — заказы субъекта со всех шардов, от старых к новым.
+func (r *Repo) FindBySubject(sub domain.Subject) ([]domain.Order, error) {
+	res, err := fanOut(r, func(s Store) ([]domain.Order, error) { return s.FindBySubject(sub) })
+	if err != nil {
+		return nil, err
+	}
+	out := slices.Concat(res...)
+	slices.SortStableFunc(out, func(a, b domain.Order) int { return a.DateCreated.Compare(b.DateCreated) })
+	return out, nil
+}
+
+// IsErased спрашивает шард заказа из Directory, а незакреплённый заказ — все шарды:
+// обезличенный до шардирования заказ мог остаться в архиве любого из них.
+func (r *Repo) IsErased(orderUID string) (bool, error) {
+	name, ok, err := r.dir.Lookup(orderUID)
+	if err != nil {
+		return false, fmt.Errorf("shard directory: %w", err)
+	}
+	if s, known := r.shards[name]; ok && known {
+		return s.IsErased(orderUID)
+	}
+	res, err := fanOut(r, func(s Store) (bool, error) { return s.IsErased(orderUID) })
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(res, true), nil
+}
+
+// EraseSubject обезличивает заказы субъекта на каждом шарде по очереди; каждый
+// шард пишет запись в свой журнал erasures. Атомарности между шардами нет: ошибка
+// одного шарда не останавливает остальные, а шарды с ошибкой пишутся в лог и
+// перечисляются в ошибке (domain.ErrUnavailable). Повтор запроса безопасен: на
+// успевших шардах заказов субъекта уже нет, и они пишут пустую запись журнала.
+// Возвращается запись первого шарда, где нашлись заказы, с order_uids всех
+// успевших шардов — и при частичной ошибке, чтобы вызывающий обновил кэш.
+func (r *Repo) EraseSubject(sub domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error) {
+	var (
+		rec    domain.Erasure
+		orders []domain.Order
+		uids   = []string{}
+		failed []string
+		done   bool // rec уже взята с успевшего шарда
+	)
+	for _, name := range r.names {
+		got, anon, err := r.shards[name].EraseSubject(sub, e)
+		if err != nil {
+			log.Printf("[shard] erase subject %s on shard %s: %v", e.SubjectDigest, name, err)
+			failed = append(failed, name)
+			continue
+		}
+		if !done || (len(uids) == 0 && len(got.OrderUIDs) > 0) {
+			rec, done = got, true
+		}
+		uids = append(uids, got.OrderUIDs...)
+		orders = append(orders, anon...)
+	}
+	rec.OrderUIDs = uids
+	if len(failed) > 0 {
+		return rec, orders, fmt.Errorf("%w: subject erased partially, failed shards: %s; retry the request",
+			domain.ErrUnavailable, strings.Join(failed, ", "))
+	}
+	return rec, orders, nil
+}
+
+// fanOut вызывает fn на всех шардах параллельно; результаты — в порядке r.names.
+func fanOut[T any](r *Repo, fn func(Store) (T, error)) ([]T, error) {
+	res := make([]T, len(r.names))
+	errs := make([]error, len(r.names))
+	var wg sync.WaitGroup
+	for i, name := range r.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res[i], errs[i] = fn(r.shards[name]); errs[i] != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// mergeNewest сливает выборки шардов в одну, от новых к старым, не длиннее limit.
+func mergeNewest(parts [][]domain.Order, limit int) []domain.Order {
+	out := slices.Concat(parts...)
+	slices.SortStableFunc(out, func(a, b domain.Order) int {
+		return cmp.Or(b.DateCreated.Compare(a.DateCreated), cmp.Compare(a.OrderUID, b.OrderUID))
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package shard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+type memStore struct {
+	orders map[string]domain.Order
+	erased map[string]bool
+	err    error
+	gets   int
+}
+
+func newMemStore(orders ...domain.Order) *memStore {
+	s := &memStore{orders: map[string]domain.Order{}}
+	for _, o := range orders {
+		s.orders[o.OrderUID] = o
+	}
+	return s
+}
+
+func (s *memStore) UpsertOrder(o domain.Order) (domain.UpsertResult, error) {
+	s.orders[o.OrderUID] = o
+	return domain.UpsertResult{Changed: true}, s.err
+}
+func (s *memStore) GetByID(id string) (domain.Order, bool, error) {
+	s.gets++
+	o, ok := s.orders[id]
+	return o, ok, s.err
+}
+func (s *memStore) LoadAll(limit int) ([]domain.Order, error) {
+	return s.Search(domain.OrderFilter{Limit: limit})
+}
+func (s *memStore) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	var out []domain.Order
+	for _, o := range s.orders {
+		if f.CustomerID == "" || o.CustomerID == f.CustomerID {
+			out = append(out, o)
+		}
+	}
+	return mergeNewest([][]domain.Order{out}, f.Limit), s.err
+}
+func (s *memStore) FindBySubject(sub domain.Subject) ([]domain.Order, error) {
+	return s.Search(domain.OrderFilter{CustomerID: sub.CustomerID})
+}
+func (s *memStore) IsErased(id string) (bool, error) { return s.erased[id], s.err }
+func (s *memStore) EraseSubject(sub domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error) {
+	if s.err != nil {
+		return e, nil, s.err
+	}
+	found, _ := s.FindBySubject(sub)
+	e.OrderUIDs = nil
+	for i, o := range found {
+		found[i] = o.Anonymize(e.Pseudonym)
+		s.orders[o.OrderUID] = found[i]
+		e.OrderUIDs = append(e.OrderUIDs, o.OrderUID)
+	}
+	return e, found, nil
+}
+
+type memDirectory map[string]string
+
+func (d memDirectory) Lookup(id string) (string, bool, error) { s, ok := d[id]; return s, ok, nil }
+func (d memDirectory) Assign(id, shard string) (string, error) {
+	if s, ok := d[id]; ok {
+		return s, nil
+	}
+	d[id] = shard
+	return shard, nil
+}
+
+func order(uid, key, customer string, day int) domain.Order {
+	return domain.Order{OrderUID: uid, ShardKey: key, CustomerID: customer,
+		DateCreated: time.Date(2025, 3, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestParseMap(t *testing.T) {
+	m, err := ParseMap("0-4=s0, 5-9=s1, vip=s1, *=s0", []string{"s0", "s1"})
+	require.NoError(t, err)
+	for key, want := range map[string]string{"0": "s0", "4": "s0", "7": "s1", "vip": "s1", "x": "s0"} {
+		got, ok := m.Shard(key)
+		require.True(t, ok)
+		require.Equal(t, want, got, key)
+	}
+
+	m, err = ParseMap("0-4=s0", []string{"s0"})
+	require.NoError(t, err)
+	_, ok := m.Shard("5")
+	require.False(t, ok)
+
+	for _, bad := range []string{"", "0=s9", "0-4=s0,3=s0", "4-0=s0", "*=s0,*=s0", "=s0"} {
+		_, err := ParseMap(bad, []string{"s0"})
+		require.Error(t, err, bad)
+	}
+}
+
+func TestRepo_RoutesAndPinsOrders(t *testing.T) {
+	s0, s1 := newMemStore(), newMemStore()
+	m, err := ParseMap("0-4=s0,5-9=s1", []string{"s0", "s1"})
+	require.NoError(t, err)
+	dir := memDirectory{}
+	r := New(map[string]Store{"s0": s0, "s1": s1}, m, dir)
+
+	_, err = r.UpsertOrder(order("u1", "7", "c1", 1))
+	require.NoError(t, err)
+	require.Contains(t, s1.orders, "u1")
+	require.Equal(t, "s1", dir["u1"])
+
+	// повторная доставка с другим ключом остаётся в закреплённом шарде
+	_, err = r.UpsertOrder(order("u1", "2", "c1", 1))
+	require.NoError(t, err)
+	require.NotContains(t, s0.orders, "u1")
+
+	_, err = r.UpsertOrder(order("u2", "x", "c1", 1))
+	require.ErrorIs(t, err, ErrUnroutable)
+	require.ErrorIs(t, err, domain.ErrInvalidOrder, "unroutable orders are not retried")
+
+	got, ok, err := r.GetByID("u1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "2", got.ShardKey)
+}
+
+func TestRepo_GetByIDFallsBackToAllShards(t *testing.T) {
+	legacy := order("old", "1", "c1", 1)
+	s0, s1 := newMemStore(), newMemStore(legacy)
+	dir := memDirectory{}
+	r := New(map[string]Store{"s0": s0, "s1": s1}, Map{def: "s0"}, dir, WithLegacyLookup())
+
+	got, ok, err := r.GetByID("old")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, legacy, got)
+	require.Equal(t, "s1", dir["old"], "found order is written back to the directory")
+
+	_, ok, err = r.GetByID("missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestRepo_GetByIDWithoutLegacyLookup(t *testing.T) {
+	s0, s1 := newMemStore(order("a", "0", "c1", 1)), newMemStore(order("old", "1", "c1", 1))
+	dir := memDirectory{"a": "s0"}
+	r := New(map[string]Store{"s0": s0, "s1": s1}, Map{def: "s0"}, dir)
+
+	for i := 0; i < 3; i++ {
+		_, ok, err := r.GetByID("missing")
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	require.Zero(t, s0.gets+s1.gets, "unknown ids do not fan out to the shards")
+
+	_, ok, err := r.GetByID("old")
+	require.NoError(t, err)
+	require.False(t, ok, "orders missing from the directory are not looked up")
+
+	got, ok, err := r.GetByID("a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "a", got.OrderUID)
+	require.Equal(t, 1, s0.gets)
+	require.Zero(t, s1.gets)
+}
+
+func TestRepo_FanOut(t *testing.T) {
+	s0 := newMemStore(order("a", "0", "c1", 1), order("c", "0", "c2", 3))
+	s1 := newMemStore(order("b", "5", "c1", 2), order("d", "5", "c1", 4))
+	r := New(map[string]Store{"s0": s0, "s1": s1}, Map{def: "s0"}, memDirectory{})
+
+	all, err := r.LoadAll(3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "c", "b"}, uids(all))
+
+	found, err := r.Search(domain.OrderFilter{CustomerID: "c1", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"d", "b", "a"}, uids(found))
+
+	subj, err := r.FindBySubject(domain.Subject{CustomerID: "c1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "d"}, uids(subj), "subject export is oldest first")
+
+	rec, anon, err := r.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{Pseudonym: "p"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b", "d"}, rec.OrderUIDs)
+	require.Len(t, anon, 3)
+
+	s1.err = errors.New("down")
+	_, err = r.LoadAll(3)
+	require.ErrorContains(t, err, "shard s1: down")
+}
+
+func TestRepo_EraseSubjectPartialFailure(t *testing.T) {
+	s0 := newMemStore(order("a", "0", "c1", 1))
+	s1 := newMemStore(order("b", "5", "c1", 2))
+	s2 := newMemStore(order("c", "9", "c1", 3))
+	r := New(map[string]Store{"s0": s0, "s1": s1, "s2": s2}, Map{def: "s0"}, memDirectory{})
+
+	s1.err = errors.New("down")
+	rec, anon, err := r.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{Pseudonym: "p"})
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+	require.ErrorContains(t, err, "failed shards: s1")
+	require.ElementsMatch(t, []string{"a", "c"}, rec.OrderUIDs, "shards after the failed one are still erased")
+	require.ElementsMatch(t, []string{"a", "c"}, uids(anon))
+
+	// повтор доделывает упавший шард, уже обезличенные заказы не находятся заново
+	s1.err = nil
+	rec, anon, err = r.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{Pseudonym: "p2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, rec.OrderUIDs)
+	require.Equal(t, "p2", anon[0].CustomerID)
+	require.Equal(t, "p", s0.orders["a"].CustomerID)
+}
+
+func uids(orders []domain.Order) []string {
+	out := make([]string, len(orders))
+	for i, o := range orders {
+		out[i] = o.OrderUID
+	}
+	return out
+}
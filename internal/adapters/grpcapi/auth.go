@@ -0,0 +1,80 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/oziev02/wb/internal/auth"
+	ordersv1 "github.com/oziev02/wb/internal/gen/orders/v1"
+)
+
+// methodRoles — минимальная роль для RPC, как requiredRole в httpapi. Методы вне
+// списка (health) публичны.
+var methodRoles = map[string]auth.Role{
+	ordersv1.OrdersService_GetOrder_FullMethodName:     auth.RoleViewer,
+	ordersv1.OrdersService_SearchOrders_FullMethodName: auth.RoleViewer,
+	ordersv1.OrdersService_WatchOrders_FullMethodName:  auth.RoleViewer,
+	ordersv1.OrdersService_IngestOrder_FullMethodName:  auth.RoleAdmin,
+}
+
+func unaryAuth(a *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(a *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authedStream подменяет контекст стрима на контекст с principal.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context { return s.ctx }
+
+// authenticate берёт учётные данные из metadata x-api-key или authorization
+// (те же значения, что и HTTP-заголовки) и проверяет роль для метода.
+func authenticate(ctx context.Context, a *auth.Authenticator, method string) (context.Context, error) {
+	need, ok := methodRoles[method]
+	if !ok {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := a.AuthenticateCredentials(first(md, auth.APIKeyHeader), first(md, "authorization"))
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			log.Printf("[auth] grpc %s: %v", method, err)
+		}
+		return nil, status.Error(codes.Unauthenticated, auth.ErrNoCredentials.Error())
+	}
+	if p.Role < need {
+		return nil, status.Error(codes.PermissionDenied, "role "+need.String()+" required")
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/domain"
+	ordersv1 "github.com/oziev02/wb/internal/gen/orders/v1"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/redact"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+// ServiceName — имя сервиса для grpc.health.v1.
+var ServiceName = ordersv1.OrdersService_ServiceDesc.ServiceName
+
+type Server struct {
+	ordersv1.UnimplementedOrdersServiceServer
+	uc     *usecase.OrderService
+	policy redact.Policy
+}
+
+// NewServer собирает grpc.Server с OrdersService, health и reflection. Вызовы
+// OrdersService проходят аутентификацию a (см. methodRoles); reflection при включённой
+// аутентификации не регистрируется — он раскрыл бы схему без проверки доступа.
+// Delivery в ответах маскируется policy по роли вызывающего, как в HTTP.
+// Health-сервер возвращается, чтобы вызывающий мог менять статус (например, при открытом breaker'е).
+func NewServer(uc *usecase.OrderService, a *auth.Authenticator, policy redact.Policy, opts ...grpc.ServerOption) (*grpc.Server, *health.Server) {
+	opts = append(opts, grpc.ChainUnaryInterceptor(unaryAuth(a)), grpc.ChainStreamInterceptor(streamAuth(a)))
+	s := grpc.NewServer(opts...)
+	ordersv1.RegisterOrdersServiceServer(s, &Server{uc: uc, policy: policy})
+	hs := health.NewServer()
+	hs.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	if !a.Enabled() {
+		reflection.Register(s)
+	}
+	return s, hs
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.GetOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	res, err := s.uc.Lookup(req.GetOrderUid())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !res.Found {
+		return nil, toStatus(domain.ErrNotFound)
+	}
+	return &ordersv1.GetOrderResponse{
+		Order: codec.OrderToProto(s.policy.Order(res.Order, roleOf(ctx))),
+		Stale: res.Cache == usecase.CacheStale,
+	}, nil
+}
+
+func (s *Server) SearchOrders(req *ordersv1.SearchOrdersRequest, stream grpc.ServerStreamingServer[ordersv1.Order]) error {
+	if req.GetLimit() < 0 {
+		return status.Error(codes.InvalidArgument, "limit must be >= 0")
+	}
+	f := domain.OrderFilter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+		Entry:           req.GetEntry(),
+		TrackNumber:     req.GetTrackNumber(),
+		Limit:           int(req.GetLimit()),
+	}
+	if req.CreatedFrom != nil {
+		f.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.CreatedTo != nil {
+		f.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+	orders, err := s.uc.Search(f)
+	if err != nil {
+		return toStatus(err)
+	}
+	role := roleOf(stream.Context())
+	for _, o := range orders {
+		if err := stream.Send(codec.OrderToProto(s.policy.Order(o, role))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) IngestOrder(_ context.Context, req *ordersv1.IngestOrderRequest) (*ordersv1.IngestOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+	o := codec.OrderFromProto(req.GetOrder())
+	if err := s.uc.Ingest(o); err != nil {
+		return nil, toStatus(err)
+	}
+	return &ordersv1.IngestOrderResponse{OrderUid: o.OrderUID}, nil
+}
+
+func (s *Server) WatchOrders(req *ordersv1.WatchOrdersRequest, stream grpc.ServerStreamingServer[ordersv1.OrderEvent]) error {
+	sub := s.uc.Watch(usecase.WatchFilter{
+		DeliveryService: req.GetDeliveryService(),
+		CustomerID:      req.GetCustomerId(),
+		Entry:           req.GetEntry(),
+	})
+	defer sub.Close()
+	metrics.StreamSubscribers.WithLabelValues("grpc").Inc()
+	defer metrics.StreamSubscribers.WithLabelValues("grpc").Dec()
+	role := roleOf(stream.Context())
+	// заголовки уходят сразу: клиент видит, что подписка установлена, ещё до первого события
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				metrics.StreamDropped.WithLabelValues("grpc").Inc()
+				return status.Error(codes.ResourceExhausted, "subscriber too slow, events dropped")
+			}
+			err := stream.Send(&ordersv1.OrderEvent{
+				Type:       ev.Type,
+				OccurredAt: timestamppb.New(ev.OccurredAt),
+				Order:      codec.OrderToProto(s.policy.Order(ev.Order, role)),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// roleOf — роль из unaryAuth/streamAuth; без principal — RoleNone, и политика
+// отдаёт самый закрытый вариант.
+func roleOf(ctx context.Context) auth.Role {
+	p, _ := auth.FromContext(ctx)
+	return p.Role
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidOrder):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, domain.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		log.Printf("[grpc] %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,217 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	ordersv1 "github.com/oziev02/wb/internal/gen/orders/v1"
+	"github.com/oziev02/wb/internal/mocks"
+	"github.com/oziev02/wb/internal/redact"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+func dial(t *testing.T, uc *usecase.OrderService) *grpc.ClientConn {
+	a, err := auth.New(auth.Config{})
+	require.NoError(t, err)
+	return dialAuth(t, uc, a)
+}
+
+func dialAuth(t *testing.T, uc *usecase.OrderService, a *auth.Authenticator) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv, _ := NewServer(uc, a, redact.Default())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func sample(uid, service string) domain.Order {
+	return domain.Order{
+		OrderUID: uid, TrackNumber: "tn", Entry: "WBIL", DeliveryService: service,
+		Delivery:    domain.Delivery{Email: "a@b.co"},
+		Payment:     domain.Payment{Transaction: uid, Amount: 1},
+		Items:       []domain.Item{{Name: "x", Price: 1, TotalPrice: 1}},
+		DateCreated: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestServer_GetSearch(t *testing.T) {
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(sample("u1", "meest"), true, nil)
+	repo.On("GetByID", "nope").Return(domain.Order{}, false, nil)
+	repo.On("Search", domain.OrderFilter{DeliveryService: "meest", Limit: usecase.DefaultSearchLimit}).
+		Return([]domain.Order{sample("u1", "meest"), sample("u2", "meest")}, nil)
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	conn := dial(t, usecase.NewOrderService(repo, c))
+	client := ordersv1.NewOrdersServiceClient(conn)
+	ctx := context.Background()
+
+	got, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.NoError(t, err)
+	require.Equal(t, "u1", got.GetOrder().GetOrderUid())
+	require.False(t, got.GetStale())
+
+	_, err = client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "nope"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.SearchOrders(ctx, &ordersv1.SearchOrdersRequest{DeliveryService: "meest"})
+	require.NoError(t, err)
+	var uids []string
+	for {
+		o, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		uids = append(uids, o.GetOrderUid())
+	}
+	require.Equal(t, []string{"u1", "u2"}, uids)
+
+	hc, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: ServiceName})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, hc.GetStatus())
+}
+
+func TestServer_IngestAndWatch(t *testing.T) {
+	repo := mocks.NewOrderRepository(t)
+	repo.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{Changed: true}, nil)
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	client := ordersv1.NewOrdersServiceClient(dial(t, usecase.NewOrderService(repo, c)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watch, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{DeliveryService: "cdek"})
+	require.NoError(t, err)
+	_, err = watch.Header() // подписка установлена
+	require.NoError(t, err)
+
+	_, err = client.IngestOrder(ctx, &ordersv1.IngestOrderRequest{Order: codec.OrderToProto(sample("skip", "meest"))})
+	require.NoError(t, err)
+	resp, err := client.IngestOrder(ctx, &ordersv1.IngestOrderRequest{Order: codec.OrderToProto(sample("u3", "cdek"))})
+	require.NoError(t, err)
+	require.Equal(t, "u3", resp.GetOrderUid())
+
+	ev, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, domain.EventOrderIngested, ev.GetType())
+	require.Equal(t, "u3", ev.GetOrder().GetOrderUid())
+
+	bad := sample("u4", "cdek")
+	bad.Items = nil
+	_, err = client.IngestOrder(ctx, &ordersv1.IngestOrderRequest{Order: codec.OrderToProto(bad)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Auth(t *testing.T) {
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(sample("u1", "meest"), true, nil)
+	repo.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{Changed: true}, nil)
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	a, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"ui:viewer:vk", "ops:admin:ak"}})
+	require.NoError(t, err)
+	conn := dialAuth(t, usecase.NewOrderService(repo, c), a)
+	client := ordersv1.NewOrdersServiceClient(conn)
+	with := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+	ingest := &ordersv1.IngestOrderRequest{Order: codec.OrderToProto(sample("u2", "cdek"))}
+
+	_, err = client.GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.GetOrder(with("wrong"), &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := client.SearchOrders(context.Background(), &ordersv1.SearchOrdersRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetOrder(with("vk"), &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.NoError(t, err)
+	_, err = client.IngestOrder(with("vk"), ingest)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.IngestOrder(with("ak"), ingest)
+	require.NoError(t, err)
+
+	// health остаётся публичным для проб, reflection при аутентификации выключен
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: ServiceName})
+	require.NoError(t, err)
+	refl, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, refl.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	_, err = refl.Recv()
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestServer_MasksDeliveryByRole(t *testing.T) {
+	o := sample("u1", "meest")
+	o.Delivery = domain.Delivery{Name: "Ivan", Phone: "+79991234545", Email: "a@mail.ru"}
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(o, true, nil).Maybe()
+	repo.On("Search", mock.Anything).Return([]domain.Order{o}, nil)
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	a, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"ui:viewer:vk", "desk:support:sk"}})
+	require.NoError(t, err)
+	svc := usecase.NewOrderService(repo, c)
+	client := ordersv1.NewOrdersServiceClient(dialAuth(t, svc, a))
+	with := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	got, err := client.GetOrder(with("vk"), &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.NoError(t, err)
+	require.Equal(t, "+7***45", got.GetOrder().GetDelivery().GetPhone())
+	require.NotEqual(t, "a@mail.ru", got.GetOrder().GetDelivery().GetEmail())
+
+	stream, err := client.SearchOrders(with("vk"), &ordersv1.SearchOrdersRequest{})
+	require.NoError(t, err)
+	found, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "+7***45", found.GetDelivery().GetPhone())
+
+	ctx, cancel := context.WithTimeout(with("vk"), 5*time.Second)
+	defer cancel()
+	watch, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{})
+	require.NoError(t, err)
+	_, err = watch.Header()
+	require.NoError(t, err)
+	repo.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{Changed: true}, nil)
+	require.NoError(t, svc.Ingest(o))
+	ev, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "+7***45", ev.GetOrder().GetDelivery().GetPhone())
+
+	// support по умолчанию видит контакты
+	got, err = client.GetOrder(with("sk"), &ordersv1.GetOrderRequest{OrderUid: "u1"})
+	require.NoError(t, err)
+	require.Equal(t, "+79991234545", got.GetOrder().GetDelivery().GetPhone())
+}
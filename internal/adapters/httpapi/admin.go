@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/oziev02/wb/internal/adapters/mq"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+// AdminHandler — служебные ручки для эксплуатации и запросов субъектов персональных данных.
+type AdminHandler struct {
+	ing   *mq.Ingestor
+	uc    *usecase.OrderService
+	authn *auth.Authenticator
+}
+
+func NewAdminHandler(ing *mq.Ingestor, uc *usecase.OrderService, a *auth.Authenticator) *AdminHandler {
+	return &AdminHandler{ing: ing, uc: uc, authn: a}
+}
+
+// Routes регистрирует служебные ручки. /admin/subjects/* выдают и необратимо
+// стирают персональные данные, поэтому без аутентификации их нет совсем (404):
+// при AUTH_ENABLED=false любой клиент считается admin.
+func (h *AdminHandler) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/ingest/status", h.ingestStatus)
+	mux.HandleFunc("/admin/ingest/pause", h.ingestPause)
+	mux.HandleFunc("/admin/ingest/resume", h.ingestResume)
+	if !h.authn.Enabled() {
+		log.Println("WARNING: AUTH_ENABLED=false, /admin/subjects/* are not registered")
+		return
+	}
+	mux.HandleFunc("/admin/subjects/export", h.subjectExport)
+	mux.HandleFunc("/admin/subjects/erase", h.subjectErase)
+}
+
+type pauseState struct {
+	Paused  bool     `json:"paused"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Пауза через админку не снимает паузу circuit breaker'а и наоборот.
+func (h *AdminHandler) ingestPause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	h.ing.Pause(mq.PauseAdmin)
+	h.writePauseState(w)
+}
+
+func (h *AdminHandler) ingestResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	h.ing.Resume(mq.PauseAdmin)
+	h.writePauseState(w)
+}
+
+func (h *AdminHandler) writePauseState(w http.ResponseWriter) {
+	paused, reasons := h.ing.Paused()
+	writeJSON(w, http.StatusOK, pauseState{Paused: paused, Reasons: reasons})
+}
+
+func (h *AdminHandler) ingestStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, h.ing.Status(ctx))
+}
+
+// subjectRequest — тело запросов субъекта. Идентификаторы передаются в теле,
+// а не в URL, чтобы не попадать в логи прокси.
+type subjectRequest struct {
+	CustomerID string `json:"customer_id"`
+	Email      string `json:"email"`
+	Reason     string `json:"reason"`
+}
+
+const maxSubjectBody = 16 << 10
+
+func decodeSubject(w http.ResponseWriter, r *http.Request) (subjectRequest, bool) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return subjectRequest{}, false
+	}
+	var req subjectRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubjectBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeBadRequest, "invalid JSON body: "+err.Error())
+		return subjectRequest{}, false
+	}
+	return req, true
+}
+
+// subjectExport отдаёт все заказы субъекта одним JSON-файлом.
+func (h *AdminHandler) subjectExport(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSubject(w, r)
+	if !ok {
+		return
+	}
+	sub := domain.Subject{CustomerID: req.CustomerID, Email: req.Email}
+	exp, err := h.uc.ExportSubject(sub)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	name := fmt.Sprintf("subject-%s-%s.json", sub.Digest()[:12], exp.GeneratedAt.Format("20060102T150405Z"))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, exp)
+}
+
+// subjectErase обезличивает заказы субъекта и возвращает запись журнала удалений.
+func (h *AdminHandler) subjectErase(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSubject(w, r)
+	if !ok {
+		return
+	}
+	by := "anonymous"
+	if p, ok := auth.FromContext(r.Context()); ok {
+		by = p.Method + ":" + p.Subject
+	}
+	rec, err := h.uc.EraseSubject(domain.Subject{CustomerID: req.CustomerID, Email: req.Email}, domain.Erasure{
+		RequestedBy: by, RequestID: RequestIDFromContext(r.Context()), Reason: req.Reason,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Printf("[admin] erasure %d by %s: %d orders", rec.ID, by, len(rec.OrderUIDs))
+	writeJSON(w, http.StatusOK, rec)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil { /* заголовки уже отправлены */
+	}
+}
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/oziev02/wb/internal/auth"
+)
+
+// requiredRole — минимальная роль для пути; RoleNone — путь публичный
+// (статика, документация, health/readiness, метрики).
+func requiredRole(path string) auth.Role {
+	switch {
+	case strings.HasPrefix(path, "/admin/"):
+		return auth.RoleAdmin
+	case path == "/orders", strings.HasPrefix(path, "/orders/"), strings.HasPrefix(path, "/order/"):
+		return auth.RoleViewer
+	default:
+		return auth.RoleNone
+	}
+}
+
+// Auth аутентифицирует запросы к защищённым путям и проверяет роль.
+// Браузерные EventSource и WebSocket не умеют ставить заголовки, поэтому для
+// /orders/stream токен принимается и из параметра access_token.
+func Auth(a *auth.Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need := requiredRole(r.URL.Path)
+		if need == auth.RoleNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Authorization, "+auth.APIKeyHeader)
+
+		p, err := a.Authenticate(r)
+		if errors.Is(err, auth.ErrNoCredentials) && strings.HasPrefix(r.URL.Path, "/orders/stream") {
+			q := r.URL.Query()
+			if tok := q.Get("access_token"); tok != "" {
+				p, err = a.AuthenticateToken(tok)
+				q.Del("access_token")
+				r.URL.RawQuery = q.Encode()
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				log.Printf("[auth] %s %s request_id=%s: %v", r.Method, r.URL.Path, RequestIDFromContext(r.Context()), err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wb"`)
+			writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, auth.ErrNoCredentials.Error())
+			return
+		}
+		if p.Role < need {
+			writeProblem(w, r, http.StatusForbidden, CodeForbidden, "role "+need.String()+" required")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// roleOf — роль вызывающего; без principal (Auth не подключён) — RoleNone,
+// и политика отдаёт самый закрытый вариант.
+func roleOf(r *http.Request) auth.Role {
+	p, _ := auth.FromContext(r.Context())
+	return p.Role
+}
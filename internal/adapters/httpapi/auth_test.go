@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/adapters/mq"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/mocks"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+func TestAuth_RolesAndPII(t *testing.T) {
+	o := domain.Order{
+		OrderUID: "u1", TrackNumber: "tn",
+		Delivery: domain.Delivery{Name: "Ivan", Phone: "+79991234545", City: "Kazan", Address: "Lenina 1", Email: "a@mail.ru"},
+		Items:    []domain.Item{{Name: "x", Price: 1, TotalPrice: 1}},
+	}
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(o, true, nil).Maybe()
+	repo.On("LoadAll", 50).Return([]domain.Order{o}, nil).Maybe()
+	repo.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{Changed: true}, nil).Maybe()
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	svc := usecase.NewOrderService(repo, c)
+	mux := http.NewServeMux()
+	NewHandler(svc).Routes(mux)
+	authn, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"ui:viewer:v", "cs:support:s"}})
+	require.NoError(t, err)
+	srv := httptest.NewServer(Auth(authn, mux))
+	defer srv.Close()
+
+	get := func(path, key string) (*http.Response, map[string]any) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+
+	resp, body := get("/order/u1", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, `Bearer realm="wb"`, resp.Header.Get("WWW-Authenticate"))
+	require.Equal(t, CodeUnauthorized, body["code"])
+
+	resp, _ = get("/healthz", "")
+	require.NotEqual(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, body = get("/order/u1", "v")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	delivery := body["delivery"].(map[string]any)
+	require.Equal(t, "Kazan", delivery["city"])
+	require.Equal(t, "+7***45", delivery["phone"])
+	require.Equal(t, "a***@mail.ru", delivery["email"])
+
+	_, body = get("/orders", "v")
+	delivery = body["orders"].([]any)[0].(map[string]any)["delivery"].(map[string]any)
+	require.Equal(t, "I***", delivery["name"])
+
+	_, body = get("/order/u1", "s")
+	delivery = body["delivery"].(map[string]any)
+	require.Equal(t, "+79991234545", delivery["phone"])
+	require.Equal(t, "Lenina 1", delivery["address"])
+
+	// EventSource: ключ в access_token, события маскируются так же.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/stream?access_token=v", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sse := bufio.NewReader(resp.Body)
+	_, err = sse.ReadString('\n')
+	require.NoError(t, err)
+	require.NoError(t, svc.Ingest(o))
+	for {
+		line, err := sse.ReadString('\n')
+		require.NoError(t, err)
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var ev domain.OrderEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &ev))
+			require.Equal(t, "u1", ev.Order.OrderUID)
+			require.Equal(t, "+7***45", ev.Order.Delivery.Phone)
+			break
+		}
+	}
+}
+
+func TestAdminRoutes_SubjectsRequireAuth(t *testing.T) {
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	svc := usecase.NewOrderService(mocks.NewOrderRepository(t), c, usecase.WithSubjectStore(subjectsFake{}))
+	ing := mq.NewIngestor(idleSource{}, codec.NewRegistry(codec.JSON{}), svc)
+
+	for _, enabled := range []bool{false, true} {
+		authn, err := auth.New(auth.Config{Enabled: enabled, APIKeys: []string{"ops:admin:a"}})
+		require.NoError(t, err)
+		mux := http.NewServeMux()
+		NewAdminHandler(ing, svc, authn).Routes(mux)
+		srv := Auth(authn, mux)
+
+		for _, path := range []string{"/admin/subjects/export", "/admin/subjects/erase"} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+			req.Header.Set(auth.APIKeyHeader, "a")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if enabled {
+				require.NotEqual(t, http.StatusNotFound, rec.Code, path)
+			} else {
+				require.Equal(t, http.StatusNotFound, rec.Code, "%s must not exist without auth", path)
+			}
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ingest/status", nil))
+		require.NotEqual(t, http.StatusNotFound, rec.Code, "ingest routes stay available")
+	}
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Границы эвристической свежести: max-age = 10% возраста последнего изменения
+// (как heuristic freshness в RFC 9111), но не меньше minMaxAge и не больше maxMaxAge.
+const (
+	minMaxAge = 5 * time.Second
+	maxMaxAge = 5 * time.Minute
+)
+
+// etagOf — сильный ETag по содержимому тела ответа.
+func etagOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified проверяет If-None-Match, а при его отсутствии — If-Modified-Since (RFC 9110 §13.2.2).
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatches — слабое сравнение, как требуется для If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl: недавно изменённые заказы кэшируются коротко, давно не менявшиеся — дольше.
+// Устаревшие копии (stale-while-error) клиент должен перепроверять всегда.
+func cacheControl(lastModified time.Time, stale bool, now time.Time) string {
+	if stale || lastModified.IsZero() {
+		return "private, no-cache"
+	}
+	maxAge := now.Sub(lastModified) / 10
+	maxAge = max(minMaxAge, min(maxAge, maxMaxAge))
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
+
+// writeCacheable отдаёт JSON с валидаторами кэша или 304, если клиентская копия актуальна.
+func writeCacheable(w http.ResponseWriter, r *http.Request, body []byte, lastModified time.Time, stale bool) {
+	etag := etagOf(body)
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	h.Set("Cache-Control", cacheControl(lastModified, stale, time.Now()))
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil { /* клиент ушёл */
+	}
+}
@@ -1,21 +1,44 @@
 package httpapi
 
 import (
-	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/redact"
 	"github.com/oziev02/wb/internal/usecase"
 )
 
-type Handler struct{ uc *usecase.OrderService }
+type Handler struct {
+	uc     *usecase.OrderService
+	policy redact.Policy
+}
+
+type HandlerOption func(*Handler)
 
-func NewHandler(uc *usecase.OrderService) *Handler { return &Handler{uc: uc} }
+// WithRedaction задаёт политику маскирования Delivery; по умолчанию redact.Default().
+func WithRedaction(p redact.Policy) HandlerOption {
+	return func(h *Handler) { h.policy = p }
+}
+
+func NewHandler(uc *usecase.OrderService, opts ...HandlerOption) *Handler {
+	h := &Handler{uc: uc, policy: redact.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
 
 func (h *Handler) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("/order/", h.getOrder)
+	mux.HandleFunc("/orders", h.listOrders)
+	mux.HandleFunc("/orders/stream", h.streamSSE)
+	mux.HandleFunc("/orders/stream/ws", h.streamWS)
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte("ok")); err != nil { /* ignore */
 		}
@@ -23,29 +46,100 @@ func (h *Handler) Routes(mux *http.ServeMux) {
 }
 
 func (h *Handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
 	id := strings.TrimPrefix(r.URL.Path, "/order/")
 	if id == "" {
-		http.Error(w, "order id required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeBadRequest, "order id required")
 		return
 	}
-	o, ok, err := h.uc.Get(id)
-	if err != nil && !ok {
-		var status = http.StatusInternalServerError
-		if errors.Is(err, contextCanceled(r.Context())) {
-			status = http.StatusRequestTimeout
-		}
-		http.Error(w, "server error", status)
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeBadRequest, err.Error())
 		return
 	}
-	if !ok {
-		http.Error(w, "not found", http.StatusNotFound)
+	res, err := h.uc.Lookup(id)
+	if res.Cache != "" {
+		w.Header().Set("X-Cache", string(res.Cache))
+	}
+	if res.Cache == usecase.CacheStale {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !res.Found {
+		writeError(w, r, domain.ErrNotFound)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(o); err != nil {
-		http.Error(w, "encode error", http.StatusInternalServerError)
+	o := h.policy.Order(res.Order, roleOf(r))
+	body, err := marshalProjected(o, fields)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+	writeCacheable(w, r, body, o.UpdatedAt, res.Cache == usecase.CacheStale)
 }
 
-// утилита, чтобы не тянуть context сюда.
-func contextCanceled(_ interface{}) error { return nil }
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type orderList struct {
+	Orders []domain.Order `json:"orders"`
+}
+
+// listOrders — последние заказы по date_created; поддерживает limit и fields.
+func (h *Handler) listOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultListLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			writeProblem(w, r, http.StatusBadRequest, CodeBadRequest,
+				fmt.Sprintf("limit must be an integer in [1, %d]", maxListLimit))
+			return
+		}
+		limit = n
+	}
+	fields, err := parseFields(q.Get("fields"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+	orders, err := h.uc.List(limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	role := roleOf(r)
+	var lastModified time.Time
+	for i, o := range orders {
+		if o.UpdatedAt.After(lastModified) {
+			lastModified = o.UpdatedAt
+		}
+		orders[i] = h.policy.Order(o, role)
+	}
+	if orders == nil {
+		orders = []domain.Order{}
+	}
+	// проекция применяется к каждому элементу orders
+	var p projection
+	if fields != nil {
+		p = projection{"orders": fields}
+	}
+	body, err := marshalProjected(orderList{Orders: orders}, p)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeCacheable(w, r, body, lastModified, false)
+}
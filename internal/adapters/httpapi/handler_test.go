@@ -0,0 +1,134 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/mocks"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+func TestGetOrder_Problems(t *testing.T) {
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "missing").Return(domain.Order{}, false, nil)
+	repo.On("GetByID", "down").Return(domain.Order{}, false, domain.ErrUnavailable)
+	repo.On("GetByID", "slow").Return(domain.Order{}, false, fmt.Errorf("query: %w", context.DeadlineExceeded))
+
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	mux := http.NewServeMux()
+	NewHandler(usecase.NewOrderService(repo, c)).Routes(mux)
+	srv := RequestID(mux)
+
+	cases := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/order/", http.StatusBadRequest, CodeBadRequest},
+		{http.MethodGet, "/order/missing", http.StatusNotFound, CodeNotFound},
+		{http.MethodGet, "/order/down", http.StatusServiceUnavailable, CodeUnavailable},
+		{http.MethodGet, "/order/slow", http.StatusGatewayTimeout, CodeTimeout},
+		{http.MethodPost, "/order/x", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+			var p Problem
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			require.Equal(t, tc.status, p.Status)
+			require.Equal(t, tc.code, p.Code)
+			require.Equal(t, "req-1", p.RequestID)
+			require.Equal(t, tc.path, p.Instance)
+		})
+	}
+}
+
+func TestGetOrder_Conditional(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(domain.Order{OrderUID: "u1", UpdatedAt: updated}, true, nil).Once()
+
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	mux := http.NewServeMux()
+	NewHandler(usecase.NewOrderService(repo, c)).Routes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/u1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, updated.Format(http.TimeFormat), rec.Header().Get("Last-Modified"))
+	require.Equal(t, "private, max-age=300", rec.Header().Get("Cache-Control"))
+
+	req := httptest.NewRequest(http.MethodGet, "/order/u1", nil)
+	req.Header.Set("If-None-Match", `W/"other", `+etag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Empty(t, rec.Body.Bytes())
+
+	req = httptest.NewRequest(http.MethodGet, "/order/u1", nil)
+	req.Header.Set("If-Modified-Since", updated.Add(-time.Second).Format(http.TimeFormat))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOrders_ProjectionAndCompression(t *testing.T) {
+	o := domain.Order{
+		OrderUID: "u1", TrackNumber: "tn",
+		Delivery: domain.Delivery{City: "Kazan", Email: "a@b.co"},
+		Items:    []domain.Item{{Name: "x", Price: 1}, {Name: "y", Price: 2}},
+	}
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(o, true, nil).Maybe()
+	repo.On("LoadAll", 2).Return([]domain.Order{o}, nil).Maybe()
+
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	mux := http.NewServeMux()
+	NewHandler(usecase.NewOrderService(repo, c)).Routes(mux)
+	srv := Compress(mux)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/u1?fields=order_uid,delivery.city,items.name", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"order_uid":"u1","delivery":{"city":"Kazan"},"items":[{"name":"x"},{"name":"y"}]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/u1?fields=delivery.nope", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders?limit=2&fields=order_uid", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.8, br;q=0.5")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	require.True(t, strings.HasPrefix(rec.Header().Get("ETag"), `W/"`))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"orders":[{"order_uid":"u1"}]}`, string(body))
+}
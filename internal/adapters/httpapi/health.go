@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HealthCheck — проверка для /readyz. Некритичные проверки только отображаются
+// (например, пауза ingest: API чтения при этом продолжает работать).
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) (string, error)
+}
+
+type Readiness struct{ checks []HealthCheck }
+
+func NewReadiness(checks ...HealthCheck) *Readiness { return &Readiness{checks: checks} }
+
+func (rd *Readiness) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("/readyz", rd.readyz)
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (rd *Readiness) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	code := http.StatusOK
+	results := make(map[string]checkResult, len(rd.checks))
+	for _, c := range rd.checks {
+		st, err := c.Check(ctx)
+		res := checkResult{Status: st}
+		if err != nil {
+			res.Status, res.Error = "down", err.Error()
+			if c.Critical {
+				code = http.StatusServiceUnavailable
+			}
+		}
+		results[c.Name] = res
+	}
+	status := "ok"
+	if code != http.StatusOK {
+		status = "unavailable"
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": results})
+}
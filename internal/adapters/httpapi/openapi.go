@@ -0,0 +1,312 @@
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// openapiSpec — контракт HTTP API. Его же использует ValidateRequests, а тест
+// openapi_test.go проверяет ответы обработчиков на соответствие.
+//
+//go:embed openapi.json
+var openapiSpec []byte
+
+const specURL = "mem://openapi.json"
+
+// ServeOpenAPI регистрирует /openapi.json; просмотрщик лежит в web/docs.
+func ServeOpenAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, r, "GET, HEAD")
+			return
+		}
+		writeCacheable(w, r, openapiSpec, time.Time{}, false)
+	})
+}
+
+// apiSpec — разобранный документ: шаблоны путей, операции и скомпилированные схемы параметров.
+type apiSpec struct {
+	doc   map[string]any
+	paths []specPath
+	c     *jsonschema.Compiler
+	n     int
+}
+
+type specPath struct {
+	template string
+	segments []string
+	ops      map[string]*specOp // метод в верхнем регистре
+}
+
+type specOp struct {
+	raw    map[string]any
+	params []specParam
+}
+
+type specParam struct {
+	name     string
+	in       string
+	required bool
+	typ      string
+	schema   *jsonschema.Schema
+}
+
+var loadSpec = sync.OnceValues(func() (*apiSpec, error) { return parseSpec(openapiSpec) })
+
+func parseSpec(data []byte) (*apiSpec, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	s := &apiSpec{doc: doc, c: jsonschema.NewCompiler()}
+	if err := s.c.AddResource(specURL, doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	paths, _ := doc["paths"].(map[string]any)
+	for tmpl, v := range paths {
+		item, _ := v.(map[string]any)
+		p := specPath{template: tmpl, segments: strings.Split(tmpl, "/"), ops: map[string]*specOp{}}
+		for method, ov := range item {
+			raw, ok := ov.(map[string]any)
+			if !ok {
+				continue
+			}
+			op := &specOp{raw: raw}
+			list, _ := raw["parameters"].([]any)
+			for _, pv := range list {
+				param, err := s.param(s.deref(pv))
+				if err != nil {
+					return nil, fmt.Errorf("openapi %s %s: %w", method, tmpl, err)
+				}
+				op.params = append(op.params, param)
+			}
+			p.ops[strings.ToUpper(method)] = op
+		}
+		s.paths = append(s.paths, p)
+	}
+	// конкретные сегменты важнее параметров: /order/list раньше /order/{id}
+	sort.Slice(s.paths, func(i, j int) bool {
+		return strings.Count(s.paths[i].template, "{") < strings.Count(s.paths[j].template, "{")
+	})
+	return s, nil
+}
+
+// deref раскрывает локальный $ref вида #/components/...
+func (s *apiSpec) deref(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return m
+	}
+	var cur any = s.doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		next, _ := cur.(map[string]any)
+		cur = next[part]
+	}
+	out, _ := cur.(map[string]any)
+	return out
+}
+
+// Schema компилирует схему из документа; $ref внутри неё указывают на компоненты спецификации.
+func (s *apiSpec) Schema(schema any) (*jsonschema.Schema, error) {
+	s.n++
+	url := fmt.Sprintf("mem://schema/%d.json", s.n)
+	if err := s.c.AddResource(url, rebaseRefs(schema)); err != nil {
+		return nil, err
+	}
+	return s.c.Compile(url)
+}
+
+func rebaseRefs(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			if ref, ok := val.(string); ok && k == "$ref" && strings.HasPrefix(ref, "#/") {
+				out[k] = specURL + ref
+				continue
+			}
+			out[k] = rebaseRefs(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, el := range t {
+			out[i] = rebaseRefs(el)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (s *apiSpec) param(raw map[string]any) (specParam, error) {
+	p := specParam{}
+	p.name, _ = raw["name"].(string)
+	p.in, _ = raw["in"].(string)
+	p.required, _ = raw["required"].(bool)
+	schema, _ := raw["schema"].(map[string]any)
+	p.typ, _ = schema["type"].(string)
+	sch, err := s.Schema(schema)
+	if err != nil {
+		return p, fmt.Errorf("parameter %s: %w", p.name, err)
+	}
+	p.schema = sch
+	return p, nil
+}
+
+// Match находит путь спецификации и значения параметров пути.
+func (s *apiSpec) Match(path string) (*specPath, map[string]string) {
+	segs := strings.Split(path, "/")
+	for i := range s.paths {
+		p := &s.paths[i]
+		if len(p.segments) != len(segs) {
+			continue
+		}
+		vars := map[string]string{}
+		ok := true
+		for j, seg := range p.segments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				if segs[j] == "" {
+					ok = false
+					break
+				}
+				vars[seg[1:len(seg)-1]] = segs[j]
+			} else if seg != segs[j] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return p, vars
+		}
+	}
+	return nil, nil
+}
+
+// Op возвращает операцию; HEAD обслуживается как GET.
+func (p *specPath) Op(method string) *specOp {
+	if op, ok := p.ops[method]; ok {
+		return op
+	}
+	if method == http.MethodHead {
+		return p.ops[http.MethodGet]
+	}
+	return nil
+}
+
+func (p *specPath) allow() string {
+	methods := make([]string, 0, len(p.ops))
+	for m := range p.ops {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// ValidateRequests проверяет метод и параметры запроса по openapi.json до вызова
+// обработчика. Пути, которых нет в спецификации (статика), пропускаются как есть.
+func ValidateRequests(next http.Handler) http.Handler {
+	spec, err := loadSpec()
+	if err != nil {
+		panic(err) // встроенная спецификация битая — ошибка сборки, ловится тестом
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, vars := spec.Match(r.URL.Path)
+		if p == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := p.Op(r.Method)
+		if op == nil {
+			methodNotAllowed(w, r, p.allow())
+			return
+		}
+		if err := op.check(r, vars); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeBadRequest, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (op *specOp) check(r *http.Request, vars map[string]string) error {
+	q := r.URL.Query()
+	for _, p := range op.params {
+		var (
+			val     string
+			present bool
+		)
+		switch p.in {
+		case "path":
+			val, present = vars[p.name]
+		case "query":
+			present = q.Has(p.name)
+			val = q.Get(p.name)
+		case "header":
+			val = r.Header.Get(p.name)
+			present = val != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.required {
+				return fmt.Errorf("%s parameter %q is required", p.in, p.name)
+			}
+			continue
+		}
+		var inst any = val
+		switch p.typ {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(val, 64); err != nil {
+				return fmt.Errorf("%s parameter %q must be of type %s", p.in, p.name, p.typ)
+			}
+			inst = json.Number(val)
+		case "boolean":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("%s parameter %q must be a boolean", p.in, p.name)
+			}
+			inst = b
+		}
+		if err := p.schema.Validate(inst); err != nil {
+			return fmt.Errorf("%s parameter %q: %s", p.in, p.name, schemaErrorText(err))
+		}
+	}
+	return nil
+}
+
+var enPrinter = message.NewPrinter(language.English)
+
+// schemaErrorText — краткий текст ошибки схемы: только листовые причины.
+func schemaErrorText(err error) string {
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err.Error()
+	}
+	var msgs []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			msgs = append(msgs, e.ErrorKind.LocalizedString(enPrinter))
+			return
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,241 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/adapters/mq"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/mocks"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+type idleSource struct{}
+
+func (idleSource) Fetch(ctx context.Context) (mq.Message, error) {
+	<-ctx.Done()
+	return mq.Message{}, ctx.Err()
+}
+func (idleSource) Ack(context.Context, mq.Message) error  { return nil }
+func (idleSource) Nack(context.Context, mq.Message) error { return nil }
+func (idleSource) Close() error                           { return nil }
+func (idleSource) Introspect(context.Context) (mq.SourceStatus, error) {
+	return mq.SourceStatus{Kind: "kafka", Partitions: []mq.PartitionStatus{{Topic: "orders", HighWater: 3, Lag: 3}}, TotalLag: 3}, nil
+}
+
+type subjectsFake struct{ orders []domain.Order }
+
+func (f subjectsFake) FindBySubject(s domain.Subject) ([]domain.Order, error) {
+	if s.CustomerID == "boom" {
+		return nil, errors.New("boom")
+	}
+	return f.orders, nil
+}
+
+func (f subjectsFake) IsErased(string) (bool, error) { return false, nil }
+
+func (f subjectsFake) EraseSubject(_ domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error) {
+	e.ID = 1
+	e.OrderUIDs = []string{}
+	var anon []domain.Order
+	for _, o := range f.orders {
+		e.OrderUIDs = append(e.OrderUIDs, o.OrderUID)
+		anon = append(anon, o.Anonymize(e.Pseudonym))
+	}
+	return e, anon, nil
+}
+
+// TestHandlers_ConformToOpenAPI прогоняет запросы через все документированные
+// маршруты и проверяет, что статус, Content-Type и тело описаны в openapi.json.
+func TestHandlers_ConformToOpenAPI(t *testing.T) {
+	spec, err := loadSpec()
+	require.NoError(t, err)
+
+	full := domain.Order{
+		SchemaVersion: 1, OrderUID: "u1", TrackNumber: "tn", Entry: "WBIL",
+		Delivery:    domain.Delivery{City: "Kazan", Email: "a@b.co"},
+		Payment:     domain.Payment{Transaction: "u1", Amount: 10},
+		Items:       []domain.Item{{Name: "x", Price: 10}},
+		DateCreated: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+	}
+	repo := mocks.NewOrderRepository(t)
+	repo.On("GetByID", "u1").Return(full, true, nil).Maybe()
+	repo.On("GetByID", "missing").Return(domain.Order{}, false, nil).Maybe()
+	repo.On("GetByID", "down").Return(domain.Order{}, false, domain.ErrUnavailable).Maybe()
+	repo.On("GetByID", "slow").Return(domain.Order{}, false, context.DeadlineExceeded).Maybe()
+	repo.On("GetByID", "boom").Return(domain.Order{}, false, errors.New("boom")).Maybe()
+	repo.On("LoadAll", 50).Return([]domain.Order{full}, nil).Maybe()
+	repo.On("LoadAll", 1).Return(nil, domain.ErrUnavailable).Maybe()
+
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	svc := usecase.NewOrderService(repo, c, usecase.WithSubjectStore(subjectsFake{orders: []domain.Order{full}}))
+	ing := mq.NewIngestor(idleSource{}, codec.NewRegistry(codec.JSON{}), svc)
+
+	authn, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"ops:admin:admin-key", "ui:viewer:viewer-key"}})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	NewHandler(svc).Routes(mux)
+	NewAdminHandler(ing, svc, authn).Routes(mux)
+	ready := true
+	NewReadiness(HealthCheck{Name: "db", Critical: true, Check: func(context.Context) (string, error) {
+		if !ready {
+			return "", errors.New("down")
+		}
+		return "up", nil
+	}}).Routes(mux)
+	mux.Handle("/metrics", metrics.Handler())
+	ServeOpenAPI(mux)
+	srv := RequestID(Compress(Auth(authn, ValidateRequests(mux))))
+
+	type call struct {
+		method, target string
+		header         map[string]string
+		body           string
+		before         func()
+		// anon: запрос без X-API-Key; по умолчанию отправляется ключ admin
+		anon bool
+		// для потоковых ручек: запрос отменяется по таймауту
+		timeout time.Duration
+	}
+	calls := []call{
+		{method: "GET", target: "/order/u1"},
+		{method: "GET", target: "/order/u1?fields=order_uid,delivery.city,items.name"},
+		{method: "GET", target: "/order/u1", header: map[string]string{"If-Modified-Since": "Fri, 03 May 2024 00:00:00 GMT"}},
+		{method: "GET", target: "/order/u1?fields=nope"},
+		{method: "GET", target: "/order/u1?fields=a,,b"},
+		{method: "GET", target: "/order/missing"},
+		{method: "GET", target: "/order/down"},
+		{method: "GET", target: "/order/slow"},
+		{method: "GET", target: "/order/boom"},
+		{method: "GET", target: "/order/u1", anon: true},
+		{method: "GET", target: "/order/u1", header: map[string]string{"X-API-Key": "wrong"}},
+		{method: "GET", target: "/order/u1", header: map[string]string{"X-API-Key": "viewer-key"}},
+		{method: "DELETE", target: "/order/u1"},
+		{method: "GET", target: "/orders"},
+		{method: "GET", target: "/orders?fields=order_uid", header: map[string]string{"Accept-Encoding": "gzip"}},
+		{method: "GET", target: "/orders?limit=0"},
+		{method: "GET", target: "/orders?limit=x"},
+		{method: "GET", target: "/orders?limit=1"},
+		{method: "GET", target: "/orders/stream?delivery_service=meest", timeout: 50 * time.Millisecond},
+		{method: "GET", target: "/orders/stream?access_token=viewer-key", anon: true, timeout: 50 * time.Millisecond},
+		{method: "GET", target: "/orders/stream", anon: true},
+		{method: "POST", target: "/orders/stream"},
+		{method: "GET", target: "/orders/stream/ws"},
+		{method: "GET", target: "/healthz", anon: true},
+		{method: "GET", target: "/readyz"},
+		{method: "GET", target: "/readyz", before: func() { ready = false }},
+		{method: "GET", target: "/metrics"},
+		{method: "GET", target: "/openapi.json", anon: true},
+		{method: "GET", target: "/admin/ingest/status"},
+		{method: "GET", target: "/admin/ingest/status", header: map[string]string{"X-API-Key": "viewer-key"}},
+		{method: "POST", target: "/admin/ingest/pause"},
+		{method: "POST", target: "/admin/ingest/resume"},
+		{method: "GET", target: "/admin/ingest/pause"},
+		{method: "POST", target: "/admin/subjects/export", body: `{"customer_id":"c1"}`},
+		{method: "POST", target: "/admin/subjects/export", body: `{}`},
+		{method: "POST", target: "/admin/subjects/export", body: `{"customer_id":`},
+		{method: "POST", target: "/admin/subjects/export", body: `{"customer_id":"boom"}`},
+		{method: "POST", target: "/admin/subjects/erase", body: `{"email":"A@b.co","reason":"ticket-1"}`},
+		{method: "POST", target: "/admin/subjects/erase", body: `{"email":"a@b.co"}`, header: map[string]string{"X-API-Key": "viewer-key"}},
+		{method: "GET", target: "/admin/subjects/erase"},
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range calls {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			if tc.before != nil {
+				tc.before()
+			}
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if !tc.anon {
+				req.Header.Set(auth.APIKeyHeader, "admin-key")
+			}
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			if tc.timeout > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), tc.timeout)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			p, _ := spec.Match(req.URL.Path)
+			require.NotNil(t, p, "path is not documented")
+			op := p.Op(tc.method)
+			if op == nil {
+				require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+				return
+			}
+			covered[tc.method+" "+p.template] = true
+
+			responses, _ := op.raw["responses"].(map[string]any)
+			resp := spec.deref(responses[strconv.Itoa(rec.Code)])
+			require.NotNil(t, resp, "status %d is not documented: %s", rec.Code, rec.Body.String())
+			content, _ := resp["content"].(map[string]any)
+			if len(content) == 0 {
+				require.Empty(t, rec.Body.Bytes())
+				return
+			}
+			mt, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+			require.NoError(t, err)
+			media, ok := content[mt].(map[string]any)
+			require.True(t, ok, "content type %s is not documented", mt)
+
+			body := rec.Body.Bytes()
+			if rec.Header().Get("Content-Encoding") == "gzip" {
+				body = gunzip(t, body)
+			}
+			sch, err := spec.Schema(media["schema"])
+			require.NoError(t, err)
+			var inst any = string(body)
+			if strings.HasSuffix(mt, "json") {
+				inst, err = jsonschemaUnmarshal(body)
+				require.NoError(t, err)
+			}
+			require.NoError(t, sch.Validate(inst), "body: %s", body)
+		})
+	}
+
+	for _, p := range spec.paths {
+		for m := range p.ops {
+			require.True(t, covered[m+" "+p.template], "%s %s is documented but not exercised", m, p.template)
+		}
+	}
+}
+
+func jsonschemaUnmarshal(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func gunzip(t *testing.T, b []byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	out, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return out
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// Коды ошибок API; попадают в поле code и в type ответа problem+json.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeRateLimited      = "rate_limited"
+	CodeTimeout          = "timeout"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem — тело ошибки по RFC 9457.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	p := Problem{
+		Type:      "urn:wb:problem:" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(p); err != nil { /* заголовки уже отправлены */
+	}
+}
+
+// writeError сопоставляет ошибку домена/хранилища со статусом и кодом.
+// Детали внутренних ошибок наружу не отдаются — только в лог с request id.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidOrder), errors.Is(err, domain.ErrInvalidSubject):
+		writeProblem(w, r, http.StatusUnprocessableEntity, CodeValidation, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeProblem(w, r, http.StatusGatewayTimeout, CodeTimeout, "storage did not respond in time")
+	case errors.Is(err, domain.ErrUnavailable):
+		w.Header().Set("Retry-After", "5")
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, err.Error())
+	default:
+		log.Printf("[http] %s %s request_id=%s: %v", r.Method, r.URL.Path, RequestIDFromContext(r.Context()), err)
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "")
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported")
+}
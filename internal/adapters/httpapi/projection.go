@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// projection — дерево запрошенных полей: fields=order_uid,delivery.city,items.name.
+// Лист (nil) означает поле целиком. Массивы проецируются поэлементно.
+type projection map[string]projection
+
+var orderType = reflect.TypeOf(domain.Order{})
+
+// parseFields разбирает параметр fields и проверяет пути по JSON-схеме domain.Order.
+// Пустой параметр — без проекции (nil).
+func parseFields(spec string) (projection, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	root := projection{}
+	for _, path := range strings.Split(spec, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if err := checkPath(orderType, path); err != nil {
+			return nil, err
+		}
+		node := root
+		parts := strings.Split(path, ".")
+		for i, name := range parts {
+			child, seen := node[name]
+			if i == len(parts)-1 {
+				node[name] = nil // поле целиком перекрывает уточнения
+				break
+			}
+			if seen && child == nil {
+				break // уже запрошено целиком
+			}
+			if child == nil {
+				child = projection{}
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return root, nil
+}
+
+func checkPath(t reflect.Type, path string) error {
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		f, ok := jsonField(t, name)
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		t = f.Type
+	}
+	return nil
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name && tag != "-" {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// marshalProjected сериализует v и оставляет только поля из p.
+func marshalProjected(v any, p projection) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil || p == nil {
+		return body, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(p.apply(doc))
+}
+
+func (p projection) apply(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(p))
+		for name, sub := range p {
+			val, ok := t[name]
+			if !ok {
+				continue
+			}
+			if sub == nil {
+				out[name] = val
+			} else {
+				out[name] = sub.apply(val)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, el := range t {
+			out[i] = p.apply(el)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/ratelimit"
+)
+
+// RateLimit ограничивает запросы корзинами токенов по маршрутам. Клиент —
+// API-ключ или субъект JWT, если запрос аутентифицирован, иначе IP-адрес.
+// Ответы 404 на маршруте расходуют отдельную, более строгую корзину: перебор
+// несуществующих id упирается в неё раньше, чем в общий лимит.
+// Подключается после Auth, чтобы principal уже был в контексте.
+func RateLimit(l *ratelimit.Limiter, trustForwarded bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := l.Route(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := clientKey(r, trustForwarded)
+		if ok, retry, reason := l.Allow(rule, client); !ok {
+			metrics.HTTPRateLimited.WithLabelValues(rule.Prefix, reason).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(retry)))
+			writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "too many requests, retry later")
+			return
+		}
+		// WebSocket перехватывает соединение — статуса ответа не будет
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == http.StatusNotFound {
+			l.Miss(rule, client)
+		}
+	})
+}
+
+// AuthFailures ограничивает перебор учётных данных, до которого RateLimit не
+// дотягивается: отклонённые Auth запросы до него не доходят. Ответ 401 расходует
+// корзину промахов адреса на маршруте, как 404 в RateLimit; пока она пуста,
+// запросы с этого адреса отклоняются ещё до проверки ключа или токена.
+// Подключается перед Auth.
+func AuthFailures(l *ratelimit.Limiter, trustForwarded bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := l.Route(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := "ip:" + clientIP(r, trustForwarded)
+		if blocked, retry := l.Blocked(rule, client); blocked {
+			metrics.HTTPRateLimited.WithLabelValues(rule.Prefix, "unauthorized").Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(retry)))
+			writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "too many requests, retry later")
+			return
+		}
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == http.StatusUnauthorized {
+			l.Miss(rule, client)
+		}
+	})
+}
+
+// clientKey — ключ корзины: аутентифицированный вызывающий или адрес.
+func clientKey(r *http.Request, trustForwarded bool) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Method != "anonymous" {
+		return p.Method + ":" + p.Subject
+	}
+	return "ip:" + clientIP(r, trustForwarded)
+}
+
+// clientIP — адрес клиента. За доверенным прокси берётся последний адрес из
+// X-Forwarded-For (его дописал сам прокси; левые значения клиент может подделать).
+// IPv6 сводится к /64: клиенту обычно выдаётся вся подсеть.
+func clientIP(r *http.Request, trustForwarded bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); trustForwarded && xff != "" {
+		host = strings.TrimSpace(xff[strings.LastIndex(xff, ",")+1:])
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap нужен http.ResponseController.
+func (sw *statusWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
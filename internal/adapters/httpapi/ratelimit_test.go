@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/ratelimit"
+)
+
+func TestRateLimit_PerKeyAndNotFound(t *testing.T) {
+	routes, err := ratelimit.ParseRoutes("/order/=1/h:3")
+	require.NoError(t, err)
+	notFound, err := ratelimit.ParseLimit("1/h:1")
+	require.NoError(t, err)
+	l := ratelimit.New(ratelimit.Config{Routes: routes, NotFound: notFound})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/order/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/order/missing" {
+			writeProblem(w, r, http.StatusNotFound, CodeNotFound, "")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	authn, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"a:viewer:ka", "b:viewer:kb"}})
+	require.NoError(t, err)
+	h := Auth(authn, RateLimit(l, false, mux))
+
+	do := func(path, key, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// промах закрывает маршрут для ключа a, хотя общая корзина ещё не пуста
+	require.Equal(t, http.StatusNotFound, do("/order/missing", "ka", "10.0.0.1").Code)
+	rec := do("/order/u1", "ka", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "3600", rec.Header().Get("Retry-After"))
+	var p Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	require.Equal(t, CodeRateLimited, p.Code)
+
+	// ключ b с того же адреса считается отдельно
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, do("/order/u1", "kb", "10.0.0.1").Code)
+	}
+	rec = do("/order/u1", "kb", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.Positive(t, retry)
+
+	// маршрут без правила не ограничивается
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, do("/healthz", "", "10.0.0.1").Code)
+	}
+}
+
+func TestAuthFailures_ClosesRouteForAddress(t *testing.T) {
+	routes, err := ratelimit.ParseRoutes("/order/=100/s:100")
+	require.NoError(t, err)
+	misses, err := ratelimit.ParseLimit("1/h:2")
+	require.NoError(t, err)
+	l := ratelimit.New(ratelimit.Config{Routes: routes, NotFound: misses})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/order/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	authn, err := auth.New(auth.Config{Enabled: true, APIKeys: []string{"a:viewer:ka"}})
+	require.NoError(t, err)
+	h := AuthFailures(l, false, Auth(authn, RateLimit(l, false, mux)))
+
+	do := func(key, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/order/u1", nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("ka", "10.0.0.1").Code)
+	require.Equal(t, http.StatusUnauthorized, do("guess-1", "10.0.0.1").Code)
+	require.Equal(t, http.StatusUnauthorized, do("", "10.0.0.1").Code)
+	// корзина промахов пуста: адрес закрыт до проверки ключа, даже верного
+	rec := do("guess-2", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "3600", rec.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusTooManyRequests, do("ka", "10.0.0.1").Code)
+
+	// другой адрес не затронут
+	require.Equal(t, http.StatusOK, do("ka", "10.0.0.2").Code)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[2001:db8:1:2:3:4:5:6]:443"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+	require.Equal(t, "2001:db8:1:2::/64", clientIP(req, false))
+	require.Equal(t, "5.6.7.8", clientIP(req, true))
+}
@@ -0,0 +1,157 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+const (
+	streamKeepAlive    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		code := CodeBadRequest
+		if status == http.StatusMethodNotAllowed {
+			code = CodeMethodNotAllowed
+		}
+		writeProblem(w, r, status, code, reason.Error())
+	},
+}
+
+func watchFilter(r *http.Request) usecase.WatchFilter {
+	q := r.URL.Query()
+	return usecase.WatchFilter{
+		DeliveryService: q.Get("delivery_service"),
+		CustomerID:      q.Get("customer_id"),
+		Entry:           q.Get("entry"),
+	}
+}
+
+// streamSSE — /orders/stream: принятые заказы как Server-Sent Events.
+// Клиент, не успевающий читать, получает событие dropped и отключается.
+func (h *Handler) streamSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	rc := http.NewResponseController(w)
+	role := roleOf(r)
+	sub := h.uc.Watch(watchFilter(r))
+	defer sub.Close()
+	metrics.StreamSubscribers.WithLabelValues("sse").Inc()
+	defer metrics.StreamSubscribers.WithLabelValues("sse").Dec()
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-store")
+	hdr.Set("X-Accel-Buffering", "no") // nginx не должен буферизовать поток
+	w.WriteHeader(http.StatusOK)
+
+	send := func(format string, args ...any) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if send("retry: 5000\n\n") != nil {
+		return
+	}
+	ping := time.NewTicker(streamKeepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			if send(": ping\n\n") != nil {
+				return
+			}
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					metrics.StreamDropped.WithLabelValues("sse").Inc()
+					_ = send("event: dropped\ndata: {\"reason\":\"slow consumer\"}\n\n")
+				}
+				return
+			}
+			ev.Order = h.policy.Order(ev.Order, role)
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if send("id: %s\nevent: %s\ndata: %s\n\n", eventID(ev), ev.Type, data) != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamWS — /orders/stream/ws: те же события, по одному JSON-сообщению на заказ.
+// Отставший клиент закрывается с кодом 1013 (try again later).
+func (h *Handler) streamWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // upgrader уже ответил
+	}
+	defer conn.Close()
+	role := roleOf(r)
+	sub := h.uc.Watch(watchFilter(r))
+	defer sub.Close()
+	metrics.StreamSubscribers.WithLabelValues("ws").Inc()
+	defer metrics.StreamSubscribers.WithLabelValues("ws").Dec()
+
+	// читаем только ради управляющих кадров и закрытия со стороны клиента
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamKeepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)) != nil {
+				return
+			}
+		case ev, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					metrics.StreamDropped.WithLabelValues("ws").Inc()
+					msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer")
+					_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(streamWriteTimeout))
+				}
+				return
+			}
+			ev.Order = h.policy.Order(ev.Order, role)
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if conn.WriteJSON(ev) != nil {
+				return
+			}
+		}
+	}
+}
+
+func eventID(ev domain.OrderEvent) string {
+	return fmt.Sprintf("%s@%d", ev.OrderUID, ev.OccurredAt.UnixNano())
+}
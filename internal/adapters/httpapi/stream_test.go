@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/mocks"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+func streamOrder(uid, service string) domain.Order {
+	return domain.Order{
+		OrderUID: uid, TrackNumber: "tn", DeliveryService: service,
+		Items: []domain.Item{{Name: "x", Price: 1, TotalPrice: 1}},
+	}
+}
+
+func TestStream_SSEAndWebSocket(t *testing.T) {
+	repo := mocks.NewOrderRepository(t)
+	repo.On("UpsertOrder", mock.Anything).Return(domain.UpsertResult{Changed: true}, nil)
+	c := cache.NewOrdersCache(10, time.Minute)
+	defer c.Close()
+	svc := usecase.NewOrderService(repo, c)
+	mux := http.NewServeMux()
+	NewHandler(svc).Routes(mux)
+	srv := httptest.NewServer(RequestID(Compress(ValidateRequests(mux))))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/stream?delivery_service=cdek", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	sse := bufio.NewReader(resp.Body)
+	line, err := sse.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "retry: 5000\n", line) // подписка установлена
+
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/orders/stream/ws", nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool {
+		// WebSocket-подписка оформляется после рукопожатия в отдельной горутине сервера
+		require.NoError(t, svc.Ingest(streamOrder("probe", "none")))
+		_ = ws.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		var ev domain.OrderEvent
+		return ws.ReadJSON(&ev) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	ws.Close()
+
+	require.NoError(t, svc.Ingest(streamOrder("u1", "meest")))
+	require.NoError(t, svc.Ingest(streamOrder("u2", "cdek")))
+
+	var event, data string
+	for data == "" {
+		line, err := sse.ReadString('\n')
+		require.NoError(t, err)
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	require.Equal(t, domain.EventOrderIngested, event)
+	var ev domain.OrderEvent
+	require.NoError(t, json.Unmarshal([]byte(data), &ev))
+	require.Equal(t, "u2", ev.OrderUID, "meest order must be filtered out")
+}
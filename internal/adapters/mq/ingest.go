@@ -0,0 +1,136 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/metrics"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+// Ingestor читает сообщения из MessageSource и передаёт заказы в OrderService.
+type Ingestor struct {
+	src    MessageSource
+	codecs *codec.Registry
+	uc     *usecase.OrderService
+	stats  stats
+	gate   *gate
+}
+
+func NewIngestor(src MessageSource, codecs *codec.Registry, uc *usecase.OrderService) *Ingestor {
+	return &Ingestor{src: src, codecs: codecs, uc: uc, gate: newGate()}
+}
+
+// Pause приостанавливает приём по указанной причине; Resume снимает только её.
+func (i *Ingestor) Pause(reason string)  { i.gate.pause(reason) }
+func (i *Ingestor) Resume(reason string) { i.gate.resume(reason) }
+
+// Paused сообщает, стоит ли цикл, и по каким причинам.
+func (i *Ingestor) Paused() (bool, []string) { return i.gate.state() }
+
+func (i *Ingestor) Run(ctx context.Context) error {
+	defer func() { _ = i.src.Close() }()
+	for {
+		if err := i.gate.wait(ctx); err != nil {
+			return err
+		}
+		m, err := i.src.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[mq] fetch: %v", err)
+			i.stats.failure(time.Now(), err)
+			continue
+		}
+		// пауза могла начаться, пока ждали сообщение: держим его без ack до снятия
+		if err := i.gate.wait(ctx); err != nil {
+			return err
+		}
+		o, err := i.codecs.Decode(m.Headers, m.Value)
+		if err != nil {
+			// битое сообщение повторять бессмысленно — подтверждаем и пропускаем
+			log.Printf("[mq] bad payload at %s/%d/%d: %v", m.Topic, m.Partition, m.Offset, err)
+			i.stats.failure(time.Now(), err)
+			metrics.IngestMessages.WithLabelValues("bad_payload").Inc()
+			i.reject(ctx, m)
+			continue
+		}
+		if err := i.ingest(ctx, o); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, domain.ErrInvalidOrder) {
+				// заказ отвергнут по содержимому — повторная доставка даст тот же результат
+				log.Printf("[mq] order rejected at %s/%d/%d: %v", m.Topic, m.Partition, m.Offset, err)
+				i.stats.failure(time.Now(), err)
+				metrics.IngestMessages.WithLabelValues("rejected").Inc()
+				i.reject(ctx, m)
+				continue
+			}
+			log.Printf("[mq] ingest failed, will retry (nack): %v", err)
+			if err = i.src.Nack(ctx, m); err != nil {
+				log.Printf("[mq] nack: %v", err)
+			}
+			continue
+		}
+		i.stats.ingested(time.Now())
+		metrics.IngestMessages.WithLabelValues("ingested").Inc()
+		if err := i.src.Ack(ctx, m); err != nil {
+			log.Printf("[mq] ack: %v", err)
+		}
+	}
+}
+
+// ingest повторяет заказ, если неудача привела к паузе (например, разомкнулся
+// breaker БД): после снятия паузы тот же заказ пробуется снова, а не теряется
+// из-за коммита более поздних смещений.
+func (i *Ingestor) ingest(ctx context.Context, o domain.Order) error {
+	for {
+		err := i.uc.Ingest(o)
+		if err == nil || errors.Is(err, domain.ErrInvalidOrder) {
+			return err
+		}
+		i.stats.failure(time.Now(), err)
+		metrics.IngestMessages.WithLabelValues("failed").Inc()
+		if paused, _ := i.gate.state(); !paused {
+			return err
+		}
+		log.Printf("[mq] ingest of %s failed while paused, will retry after resume: %v", o.OrderUID, err)
+		if err := i.gate.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// reject снимает сообщение с доставки: через Term, если источник умеет, иначе через Ack.
+func (i *Ingestor) reject(ctx context.Context, m Message) {
+	var err error
+	if t, ok := i.src.(Terminator); ok {
+		err = t.Term(ctx, m)
+	} else {
+		err = i.src.Ack(ctx, m)
+	}
+	if err != nil {
+		log.Printf("[mq] reject %s/%d/%d: %v", m.Topic, m.Partition, m.Offset, err)
+	}
+}
+
+// Status объединяет счётчики цикла с данными брокера, если источник их отдаёт.
+func (i *Ingestor) Status(ctx context.Context) Status {
+	st := i.stats.snapshot(time.Now())
+	st.Paused, st.PauseReasons = i.gate.state()
+	if in, ok := i.src.(Introspector); ok {
+		src, err := in.Introspect(ctx)
+		if err != nil {
+			st.SourceError = err.Error()
+		} else {
+			st.Source = &src
+		}
+	}
+	return st
+}
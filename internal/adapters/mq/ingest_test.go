@@ -0,0 +1,107 @@
+package mq
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+type chanSource struct {
+	ch    chan Message
+	acked atomic.Int32
+}
+
+// termSource — источник с Term, как NATS.
+type termSource struct {
+	chanSource
+	termed atomic.Int32
+}
+
+func (s *termSource) Term(context.Context, Message) error { s.termed.Add(1); return nil }
+
+func (s *chanSource) Fetch(ctx context.Context) (Message, error) {
+	select {
+	case m := <-s.ch:
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+func (s *chanSource) Ack(context.Context, Message) error  { s.acked.Add(1); return nil }
+func (s *chanSource) Nack(context.Context, Message) error { return nil }
+func (s *chanSource) Close() error                        { return nil }
+
+type memRepo struct{ upserts atomic.Int32 }
+
+func (r *memRepo) UpsertOrder(domain.Order) (domain.UpsertResult, error) {
+	r.upserts.Add(1)
+	return domain.UpsertResult{Changed: true}, nil
+}
+func (r *memRepo) GetByID(string) (domain.Order, bool, error)        { return domain.Order{}, false, nil }
+func (r *memRepo) LoadAll(int) ([]domain.Order, error)               { return nil, nil }
+func (r *memRepo) Search(domain.OrderFilter) ([]domain.Order, error) { return nil, nil }
+
+type nopCache struct{}
+
+func (nopCache) Get(string) (domain.Order, bool) { return domain.Order{}, false }
+func (nopCache) Set(domain.Order)                {}
+func (nopCache) BulkSet([]domain.Order)          {}
+
+func TestIngestor_PauseResume(t *testing.T) {
+	src := &chanSource{ch: make(chan Message, 1)}
+	repo := &memRepo{}
+	ing := NewIngestor(src, codec.NewRegistry(codec.JSON{}), usecase.NewOrderService(repo, nopCache{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- ing.Run(ctx) }()
+
+	ing.Pause(PauseAdmin)
+	ing.Pause(PauseBreaker)
+	src.ch <- Message{Value: []byte(`{"order_uid":"u1","track_number":"t","items":[{"name":"x"}]}`)}
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, repo.upserts.Load())
+
+	// снятие одной причины не возобновляет приём
+	ing.Resume(PauseAdmin)
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, repo.upserts.Load())
+	paused, reasons := ing.Paused()
+	require.True(t, paused)
+	require.Equal(t, []string{PauseBreaker}, reasons)
+
+	ing.Resume(PauseBreaker)
+	require.Eventually(t, func() bool { return src.acked.Load() == 1 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(1), repo.upserts.Load())
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestIngestor_RejectsInvalidOrders(t *testing.T) {
+	src := &termSource{chanSource: chanSource{ch: make(chan Message, 2)}}
+	repo := &memRepo{}
+	ing := NewIngestor(src, codec.NewRegistry(codec.JSON{}), usecase.NewOrderService(repo, nopCache{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- ing.Run(ctx) }()
+
+	src.ch <- Message{Value: []byte(`{"order_uid":"u1"}`)} // без позиций — невалиден
+	src.ch <- Message{Value: []byte(`not json`)}
+	require.Eventually(t, func() bool { return src.termed.Load() == 2 }, time.Second, 5*time.Millisecond)
+	require.Zero(t, src.acked.Load())
+	require.Zero(t, repo.upserts.Load())
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
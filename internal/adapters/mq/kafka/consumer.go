@@ -2,62 +2,97 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
 	"time"
 
 	kafkago "github.com/segmentio/kafka-go"
 
-	"github.com/oziev02/wb/internal/domain"
-	"github.com/oziev02/wb/internal/usecase"
+	"github.com/oziev02/wb/internal/adapters/mq"
 )
 
 type Config struct {
-	Brokers []string
-	Topic   string
-	GroupID string
+	Brokers  []string
+	Topic    string
+	GroupID  string
+	Security Security
 }
 
+var errForeignMessage = errors.New("kafka: message was not fetched by this consumer")
+
+// Consumer — mq.MessageSource поверх consumer group Kafka.
 type Consumer struct {
 	reader *kafkago.Reader
-	uc     *usecase.OrderService
+	client adminClient
+	cfg    Config
+}
+
+// adminClient — запросы *kafkago.Client, нужные Introspect.
+type adminClient interface {
+	Metadata(ctx context.Context, req *kafkago.MetadataRequest) (*kafkago.MetadataResponse, error)
+	DescribeGroups(ctx context.Context, req *kafkago.DescribeGroupsRequest) (*kafkago.DescribeGroupsResponse, error)
+	OffsetFetch(ctx context.Context, req *kafkago.OffsetFetchRequest) (*kafkago.OffsetFetchResponse, error)
+	ListOffsets(ctx context.Context, req *kafkago.ListOffsetsRequest) (*kafkago.ListOffsetsResponse, error)
 }
 
-func NewConsumer(cfg Config, uc *usecase.OrderService) *Consumer {
+func NewConsumer(cfg Config) (*Consumer, error) {
+	dialer, err := NewDialer(cfg.Security)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := NewTransport(cfg.Security)
+	if err != nil {
+		return nil, err
+	}
 	r := kafkago.NewReader(kafkago.ReaderConfig{
 		Brokers:        cfg.Brokers,
 		GroupID:        cfg.GroupID,
 		Topic:          cfg.Topic,
+		Dialer:         dialer,
 		CommitInterval: time.Second,
 	})
-	return &Consumer{reader: r, uc: uc}
+	client := &kafkago.Client{
+		Addr:      kafkago.TCP(cfg.Brokers...),
+		Timeout:   5 * time.Second,
+		Transport: transport,
+	}
+	return &Consumer{reader: r, client: client, cfg: cfg}, nil
 }
 
-func (c *Consumer) Run(ctx context.Context) error {
-	defer func() { _ = c.reader.Close() }()
-	for {
-		m, err := c.reader.FetchMessage(ctx)
-		if err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			log.Printf("[kafka] fetch: %v", err)
-			continue
-		}
-		var o domain.Order
-		if err := json.Unmarshal(m.Value, &o); err != nil {
-			log.Printf("[kafka] bad json at offset %d: %v", m.Offset, err)
-			if err = c.reader.CommitMessages(ctx, m); err != nil {
-				log.Printf("[kafka] commit after bad json: %v", err)
-			}
-			continue
-		}
-		if err := c.uc.Ingest(o); err != nil {
-			log.Printf("[kafka] ingest failed, will retry (no commit): %v", err)
-			continue
-		}
-		if err := c.reader.CommitMessages(ctx, m); err != nil {
-			log.Printf("[kafka] commit: %v", err)
-		}
+func (c *Consumer) Fetch(ctx context.Context) (mq.Message, error) {
+	m, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return mq.Message{}, err
+	}
+	return toMessage(m), nil
+}
+
+func (c *Consumer) Ack(ctx context.Context, m mq.Message) error {
+	km, ok := m.Native.(kafkago.Message)
+	if !ok {
+		return errForeignMessage
+	}
+	return c.reader.CommitMessages(ctx, km)
+}
+
+// Nack ничего не коммитит: сообщение будет перечитано после ребалансировки
+// или рестарта, если к тому моменту не закоммичено более позднее смещение.
+func (c *Consumer) Nack(context.Context, mq.Message) error { return nil }
+
+func (c *Consumer) Close() error { return c.reader.Close() }
+
+func toMessage(m kafkago.Message) mq.Message {
+	h := make(map[string]string, len(m.Headers))
+	for _, kv := range m.Headers {
+		h[kv.Key] = string(kv.Value)
+	}
+	return mq.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   h,
+		Time:      m.Time,
+		Native:    m,
 	}
 }
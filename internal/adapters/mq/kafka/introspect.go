@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/oziev02/wb/internal/adapters/mq"
+)
+
+// Introspect собирает состояние группы: назначение партиций (DescribeGroups),
+// закоммиченные смещения (OffsetFetch) и high-water mark (ListOffsets).
+func (c *Consumer) Introspect(ctx context.Context) (mq.SourceStatus, error) {
+	st := mq.SourceStatus{Kind: "kafka", Group: c.cfg.GroupID}
+
+	meta, err := c.client.Metadata(ctx, &kafkago.MetadataRequest{Topics: []string{c.cfg.Topic}})
+	if err != nil {
+		return st, fmt.Errorf("metadata: %w", err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != c.cfg.Topic {
+			continue
+		}
+		if t.Error != nil {
+			return st, fmt.Errorf("metadata %s: %w", t.Name, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	sort.Ints(partitions)
+
+	members := map[int]string{}
+	groups, err := c.client.DescribeGroups(ctx, &kafkago.DescribeGroupsRequest{GroupIDs: []string{c.cfg.GroupID}})
+	if err != nil {
+		return st, fmt.Errorf("describe group: %w", err)
+	}
+	for _, g := range groups.Groups {
+		if g.Error != nil {
+			return st, fmt.Errorf("describe group %s: %w", g.GroupID, g.Error)
+		}
+		st.GroupState = g.GroupState
+		for _, m := range g.Members {
+			for _, t := range m.MemberAssignments.Topics {
+				if t.Topic != c.cfg.Topic {
+					continue
+				}
+				for _, p := range t.Partitions {
+					members[p] = m.ClientID + "@" + m.ClientHost
+				}
+			}
+		}
+	}
+
+	committed, err := c.client.OffsetFetch(ctx, &kafkago.OffsetFetchRequest{
+		GroupID: c.cfg.GroupID,
+		Topics:  map[string][]int{c.cfg.Topic: partitions},
+	})
+	if err != nil {
+		return st, fmt.Errorf("offset fetch: %w", err)
+	}
+	if committed.Error != nil {
+		return st, fmt.Errorf("offset fetch: %w", committed.Error)
+	}
+	commits := map[int]int64{}
+	for _, p := range committed.Topics[c.cfg.Topic] {
+		commits[p.Partition] = p.CommittedOffset
+	}
+
+	reqs := make([]kafkago.OffsetRequest, 0, len(partitions))
+	for _, p := range partitions {
+		reqs = append(reqs, kafkago.LastOffsetOf(p))
+	}
+	offsets, err := c.client.ListOffsets(ctx, &kafkago.ListOffsetsRequest{
+		Topics: map[string][]kafkago.OffsetRequest{c.cfg.Topic: reqs},
+	})
+	if err != nil {
+		return st, fmt.Errorf("list offsets: %w", err)
+	}
+	hw := map[int]int64{}
+	for _, p := range offsets.Topics[c.cfg.Topic] {
+		if p.Error == nil {
+			hw[p.Partition] = p.LastOffset
+		}
+	}
+
+	for _, p := range partitions {
+		ps := mq.PartitionStatus{
+			Topic:     c.cfg.Topic,
+			Partition: p,
+			Member:    members[p],
+			Committed: commits[p],
+			HighWater: hw[p],
+		}
+		// -1 — группа ещё ничего не коммитила в эту партицию; без high-water mark
+		// (ошибка ListOffsets по партиции) лаг неизвестен и в сумму не идёт
+		_, known := hw[p]
+		switch {
+		case !known:
+		case ps.Committed >= 0:
+			ps.Lag = ps.HighWater - ps.Committed
+		default:
+			ps.Lag = ps.HighWater
+		}
+		st.TotalLag += ps.Lag
+		st.Partitions = append(st.Partitions, ps)
+	}
+
+	rs := c.reader.Stats()
+	st.Client = map[string]any{
+		"client_id":      rs.ClientID,
+		"reader_lag":     rs.Lag,
+		"reader_offset":  rs.Offset,
+		"queue_length":   rs.QueueLength,
+		"queue_capacity": rs.QueueCapacity,
+	}
+	return st, nil
+}
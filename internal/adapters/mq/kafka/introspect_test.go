@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/adapters/mq"
+)
+
+// adminStub отвечает на запросы Introspect заготовленными ответами брокера.
+type adminStub struct {
+	partitions []int
+	members    map[string][]int // клиент → назначенные партиции
+	committed  map[int]int64
+	high       []kafkago.PartitionOffsets
+	listed     *kafkago.ListOffsetsRequest
+}
+
+func (s *adminStub) Metadata(context.Context, *kafkago.MetadataRequest) (*kafkago.MetadataResponse, error) {
+	t := kafkago.Topic{Name: "orders"}
+	for _, p := range s.partitions {
+		t.Partitions = append(t.Partitions, kafkago.Partition{Topic: "orders", ID: p})
+	}
+	return &kafkago.MetadataResponse{Topics: []kafkago.Topic{t}}, nil
+}
+
+func (s *adminStub) DescribeGroups(context.Context, *kafkago.DescribeGroupsRequest) (*kafkago.DescribeGroupsResponse, error) {
+	g := kafkago.DescribeGroupsResponseGroup{GroupID: "wb", GroupState: "Stable"}
+	for client, ps := range s.members {
+		m := kafkago.DescribeGroupsResponseMember{ClientID: client, ClientHost: "/10.0.0.1"}
+		m.MemberAssignments.Topics = []kafkago.GroupMemberTopic{{Topic: "orders", Partitions: ps}}
+		g.Members = append(g.Members, m)
+	}
+	return &kafkago.DescribeGroupsResponse{Groups: []kafkago.DescribeGroupsResponseGroup{g}}, nil
+}
+
+func (s *adminStub) OffsetFetch(_ context.Context, req *kafkago.OffsetFetchRequest) (*kafkago.OffsetFetchResponse, error) {
+	var ps []kafkago.OffsetFetchPartition
+	for _, p := range req.Topics["orders"] {
+		off, ok := s.committed[p]
+		if !ok {
+			off = -1
+		}
+		ps = append(ps, kafkago.OffsetFetchPartition{Partition: p, CommittedOffset: off})
+	}
+	return &kafkago.OffsetFetchResponse{Topics: map[string][]kafkago.OffsetFetchPartition{"orders": ps}}, nil
+}
+
+func (s *adminStub) ListOffsets(_ context.Context, req *kafkago.ListOffsetsRequest) (*kafkago.ListOffsetsResponse, error) {
+	s.listed = req
+	return &kafkago.ListOffsetsResponse{Topics: map[string][]kafkago.PartitionOffsets{"orders": s.high}}, nil
+}
+
+func TestConsumer_IntrospectLag(t *testing.T) {
+	stub := &adminStub{
+		partitions: []int{2, 0, 1},
+		members:    map[string][]int{"wb-1": {0, 1}, "wb-2": {2}},
+		committed:  map[int]int64{0: 90, 1: 100}, // в партицию 2 группа ещё не коммитила
+		high: []kafkago.PartitionOffsets{
+			{Partition: 0, LastOffset: 100},
+			{Partition: 1, LastOffset: 100},
+			{Partition: 2, LastOffset: 7},
+		},
+	}
+	r := kafkago.NewReader(kafkago.ReaderConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "orders"})
+	t.Cleanup(func() { _ = r.Close() })
+	c := &Consumer{reader: r, client: stub, cfg: Config{Topic: "orders", GroupID: "wb"}}
+
+	st, err := c.Introspect(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, stub.listed.Topics["orders"], 3)
+	for _, req := range stub.listed.Topics["orders"] {
+		require.Equal(t, kafkago.LastOffset, req.Timestamp, "high-water mark запрашивается как последнее смещение")
+	}
+	require.Equal(t, "Stable", st.GroupState)
+	require.Equal(t, []mq.PartitionStatus{
+		{Topic: "orders", Partition: 0, Member: "wb-1@/10.0.0.1", Committed: 90, HighWater: 100, Lag: 10},
+		{Topic: "orders", Partition: 1, Member: "wb-1@/10.0.0.1", Committed: 100, HighWater: 100, Lag: 0},
+		{Topic: "orders", Partition: 2, Member: "wb-2@/10.0.0.1", Committed: -1, HighWater: 7, Lag: 7},
+	}, st.Partitions)
+	require.Equal(t, int64(17), st.TotalLag)
+}
+
+func TestConsumer_IntrospectSkipsFailedOffsets(t *testing.T) {
+	stub := &adminStub{
+		partitions: []int{0, 1},
+		committed:  map[int]int64{0: 5, 1: 5},
+		high: []kafkago.PartitionOffsets{
+			{Partition: 0, LastOffset: 8},
+			{Partition: 1, LastOffset: 50, Error: kafkago.NotLeaderForPartition},
+		},
+	}
+	r := kafkago.NewReader(kafkago.ReaderConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "orders"})
+	t.Cleanup(func() { _ = r.Close() })
+	c := &Consumer{reader: r, client: stub, cfg: Config{Topic: "orders", GroupID: "wb"}}
+
+	st, err := c.Introspect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), st.Partitions[0].Lag)
+	require.Zero(t, st.Partitions[1].HighWater, "ответ с ошибкой партиции не учитывается")
+	require.Zero(t, st.Partitions[1].Lag)
+	require.Equal(t, int64(3), st.TotalLag)
+}
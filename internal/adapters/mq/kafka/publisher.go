@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+type PublisherConfig struct {
+	Brokers  []string
+	Topic    string
+	Security Security
+}
+
+// Publisher отправляет события outbox в Kafka. Ключ — order_uid, поэтому
+// события одного заказа попадают в одну партицию и сохраняют порядок.
+type Publisher struct {
+	w *kafkago.Writer
+}
+
+func NewPublisher(cfg PublisherConfig) (*Publisher, error) {
+	transport, err := NewTransport(cfg.Security)
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{w: &kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+		Transport:    transport,
+	}}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, recs []domain.OutboxRecord) error {
+	msgs := make([]kafkago.Message, 0, len(recs))
+	for _, r := range recs {
+		msgs = append(msgs, kafkago.Message{
+			Key:     []byte(r.Key),
+			Value:   r.Payload,
+			Headers: []kafkago.Header{{Key: "event-type", Value: []byte(r.Type)}},
+		})
+	}
+	return p.w.WriteMessages(ctx, msgs...)
+}
+
+func (p *Publisher) Close() error { return p.w.Close() }
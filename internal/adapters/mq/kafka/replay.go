@@ -0,0 +1,162 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/usecase"
+)
+
+// ReplayConfig задаёт диапазон одной партиции. Границы по смещению имеют приоритет
+// над границами по времени; отрицательное смещение означает «не задано».
+type ReplayConfig struct {
+	Brokers    []string
+	Topic      string
+	Partition  int
+	Security   Security
+	FromOffset int64
+	ToOffset   int64 // включительно
+	FromTime   time.Time
+	ToTime     time.Time // исключительно
+	DryRun     bool
+	// Idle — сколько ждать следующего сообщения диапазона (0 — defaultReplayIdle).
+	// Смещение to могло исчезнуть (compaction, retention, control-записи
+	// транзакций), и без этой границы FetchMessage ждал бы его вечно.
+	Idle time.Duration
+}
+
+const defaultReplayIdle = 10 * time.Second
+
+type ReplayReport struct {
+	From       int64 `json:"from_offset"`
+	To         int64 `json:"to_offset"`
+	Read       int   `json:"read"`
+	Accepted   int   `json:"accepted"`
+	Rejected   int   `json:"rejected"`
+	Unchanged  int   `json:"unchanged"`
+	BadPayload int   `json:"bad_payload"`
+	DryRun     bool  `json:"dry_run"`
+}
+
+// Replay перечитывает диапазон партиции напрямую (без consumer group, смещения
+// основной группы не трогаются) и прогоняет каждый заказ через OrderService.Reingest.
+func Replay(ctx context.Context, cfg ReplayConfig, codecs *codec.Registry, uc *usecase.OrderService) (ReplayReport, error) {
+	rep := ReplayReport{DryRun: cfg.DryRun}
+	if len(cfg.Brokers) == 0 {
+		return rep, errors.New("replay: no brokers")
+	}
+	dialer, err := NewDialer(cfg.Security)
+	if err != nil {
+		return rep, err
+	}
+	from, to, err := resolveRange(ctx, dialer, cfg)
+	if err != nil {
+		return rep, err
+	}
+	rep.From, rep.To = from, to
+	if from > to {
+		return rep, nil
+	}
+
+	r := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   cfg.Brokers,
+		Topic:     cfg.Topic,
+		Partition: cfg.Partition,
+		Dialer:    dialer,
+	})
+	defer func() { _ = r.Close() }()
+	if err := r.SetOffset(from); err != nil {
+		return rep, fmt.Errorf("set offset: %w", err)
+	}
+
+	idle := cfg.Idle
+	if idle <= 0 {
+		idle = defaultReplayIdle
+	}
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		m, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			log.Printf("[replay] no messages for %s at offset %d, offsets up to %d are gone; stopping", idle, r.Offset(), to)
+			return rep, nil
+		}
+		if err != nil {
+			return rep, fmt.Errorf("fetch: %w", err)
+		}
+		if m.Offset > to {
+			return rep, nil
+		}
+		rep.Read++
+		km := toMessage(m)
+		o, err := codecs.Decode(km.Headers, km.Value)
+		if err != nil {
+			rep.BadPayload++
+			log.Printf("[replay] bad payload at offset %d: %v", m.Offset, err)
+		} else {
+			outcome, err := uc.Reingest(o, cfg.DryRun)
+			switch outcome {
+			case usecase.OutcomeAccepted:
+				rep.Accepted++
+			case usecase.OutcomeUnchanged:
+				rep.Unchanged++
+			case usecase.OutcomeRejected:
+				rep.Rejected++
+				log.Printf("[replay] rejected %s at offset %d: %v", o.OrderUID, m.Offset, err)
+			case usecase.OutcomeFailed:
+				return rep, fmt.Errorf("offset %d: %w", m.Offset, err)
+			}
+		}
+		// после high-water mark сообщений нет: дальше to не наступит
+		if m.Offset >= to || (m.HighWaterMark > 0 && m.Offset+1 >= m.HighWaterMark) {
+			return rep, nil
+		}
+	}
+}
+
+// resolveRange переводит границы конфигурации в смещения [from, to] по данным лидера партиции.
+func resolveRange(ctx context.Context, dialer *kafkago.Dialer, cfg ReplayConfig) (int64, int64, error) {
+	conn, err := dialer.DialLeader(ctx, "tcp", cfg.Brokers[0], cfg.Topic, cfg.Partition)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dial leader: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("read offsets: %w", err)
+	}
+	from, to := first, last-1 // last — high-water mark, следующего сообщения ещё нет
+
+	switch {
+	case cfg.FromOffset >= 0:
+		from = max(cfg.FromOffset, first)
+	case !cfg.FromTime.IsZero():
+		if from, err = conn.ReadOffset(cfg.FromTime); err != nil {
+			return 0, 0, fmt.Errorf("offset at %s: %w", cfg.FromTime, err)
+		}
+		if from < 0 { // сообщений позже FromTime нет
+			from = last
+		}
+	}
+	switch {
+	case cfg.ToOffset >= 0:
+		to = min(cfg.ToOffset, last-1)
+	case !cfg.ToTime.IsZero():
+		end, err := conn.ReadOffset(cfg.ToTime)
+		if err != nil {
+			return 0, 0, fmt.Errorf("offset at %s: %w", cfg.ToTime, err)
+		}
+		if end < 0 {
+			end = last
+		}
+		to = min(end, last) - 1
+	}
+	return from, to, nil
+}
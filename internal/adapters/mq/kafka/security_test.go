@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert пишет самоподписанный сертификат и его ключ в PEM-файлы.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wb-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestSecurity_Build(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeCert(t, dir)
+	notPEM := filepath.Join(dir, "ca.txt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	cases := []struct {
+		name    string
+		sec     Security
+		tls     bool
+		mech    string
+		wantErr string
+	}{
+		{name: "plaintext by default"},
+		{name: "tls without files", sec: Security{TLS: true}, tls: true},
+		{name: "tls with ca and client cert", sec: Security{TLS: true, CAFile: cert, CertFile: cert, KeyFile: key}, tls: true},
+		{name: "files ignored without tls", sec: Security{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "plain", sec: Security{SASLMechanism: "PLAIN", Username: "u", Password: "p"}, mech: "PLAIN"},
+		{name: "scram-sha-256", sec: Security{SASLMechanism: "scram-sha-256", Username: "u", Password: "p"}, mech: "SCRAM-SHA-256"},
+		{name: "scram-sha-512 over tls", sec: Security{TLS: true, SASLMechanism: "SCRAM-SHA-512", Username: "u", Password: "p"}, tls: true, mech: "SCRAM-SHA-512"},
+		{name: "unknown mechanism", sec: Security{SASLMechanism: "GSSAPI"}, wantErr: `kafka sasl: unsupported mechanism "GSSAPI"`},
+		{name: "missing ca", sec: Security{TLS: true, CAFile: filepath.Join(dir, "missing.pem")}, wantErr: "kafka tls: read ca"},
+		{name: "ca without certificates", sec: Security{TLS: true, CAFile: notPEM}, wantErr: "kafka tls: no certificates in"},
+		{name: "cert without key", sec: Security{TLS: true, CertFile: cert}, wantErr: "kafka tls: client cert"},
+		{name: "key is not a cert", sec: Security{TLS: true, CertFile: key, KeyFile: key}, wantErr: "kafka tls: client cert"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDialer(tc.sec)
+			tr, trErr := NewTransport(tc.sec)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				require.ErrorContains(t, trErr, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, trErr)
+
+			require.Equal(t, tc.tls, d.TLS != nil)
+			require.Equal(t, tc.tls, tr.TLS != nil)
+			if tc.tls {
+				require.Equal(t, tc.sec.CAFile != "", d.TLS.RootCAs != nil)
+				require.Len(t, d.TLS.Certificates, map[bool]int{true: 1}[tc.sec.CertFile != ""])
+			}
+			if tc.mech == "" {
+				require.Nil(t, d.SASLMechanism)
+				require.Nil(t, tr.SASL)
+				return
+			}
+			require.Equal(t, tc.mech, d.SASLMechanism.Name())
+			require.Equal(t, tc.mech, tr.SASL.Name())
+		})
+	}
+}
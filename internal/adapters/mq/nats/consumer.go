@@ -0,0 +1,174 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/oziev02/wb/internal/adapters/mq"
+)
+
+type Config struct {
+	URL     string
+	Stream  string
+	Subject string
+	Durable string
+	AckWait time.Duration
+	// NakDelay — задержка повторной доставки после первой неудачи; удваивается
+	// с каждой следующей доставкой, но не больше NakMaxDelay.
+	NakDelay    time.Duration
+	NakMaxDelay time.Duration
+}
+
+var errForeignMessage = errors.New("nats: message was not fetched by this consumer")
+
+// Consumer — mq.MessageSource поверх durable pull-консьюмера JetStream.
+type Consumer struct {
+	nc      *natsgo.Conn
+	cons    jetstream.Consumer
+	backoff backoff
+}
+
+func NewConsumer(ctx context.Context, cfg Config) (*Consumer, error) {
+	nc, err := natsgo.Connect(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("nats connect: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("jetstream: %w", err)
+	}
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.Subject},
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("stream %s: %w", cfg.Stream, err)
+	}
+	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("consumer %s: %w", cfg.Durable, err)
+	}
+	return &Consumer{nc: nc, cons: cons, backoff: backoff{base: cfg.NakDelay, max: cfg.NakMaxDelay}}, nil
+}
+
+// Fetch ждёт сообщение короткими pull-запросами, чтобы вовремя заметить отмену ctx.
+func (c *Consumer) Fetch(ctx context.Context) (mq.Message, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return mq.Message{}, err
+		}
+		msg, err := c.cons.Next(jetstream.FetchMaxWait(time.Second))
+		if errors.Is(err, natsgo.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return mq.Message{}, err
+		}
+		return toMessage(msg), nil
+	}
+}
+
+func (c *Consumer) Ack(_ context.Context, m mq.Message) error {
+	msg, ok := m.Native.(jetstream.Msg)
+	if !ok {
+		return errForeignMessage
+	}
+	return msg.Ack()
+}
+
+// Nack просит JetStream передоставить сообщение с задержкой, растущей с числом
+// доставок: пока БД недоступна, сообщение не крутится в горячем цикле.
+func (c *Consumer) Nack(_ context.Context, m mq.Message) error {
+	msg, ok := m.Native.(jetstream.Msg)
+	if !ok {
+		return errForeignMessage
+	}
+	var delivered uint64 = 1
+	if meta, err := msg.Metadata(); err == nil {
+		delivered = meta.NumDelivered
+	}
+	return msg.NakWithDelay(c.backoff.delay(delivered))
+}
+
+// Term снимает сообщение с доставки насовсем: JetStream его больше не передоставит.
+func (c *Consumer) Term(_ context.Context, m mq.Message) error {
+	msg, ok := m.Native.(jetstream.Msg)
+	if !ok {
+		return errForeignMessage
+	}
+	return msg.Term()
+}
+
+type backoff struct {
+	base, max time.Duration
+}
+
+// delay — base * 2^(delivered-1), не больше max.
+func (b backoff) delay(delivered uint64) time.Duration {
+	d := b.base
+	for n := uint64(1); n < delivered && d < b.max; n++ {
+		d *= 2
+	}
+	return min(d, b.max)
+}
+
+func (c *Consumer) Close() error {
+	return c.nc.Drain()
+}
+
+func toMessage(msg jetstream.Msg) mq.Message {
+	h := make(map[string]string, len(msg.Headers()))
+	for k := range msg.Headers() {
+		h[k] = msg.Headers().Get(k)
+	}
+	m := mq.Message{
+		Topic:   msg.Subject(),
+		Key:     []byte(h["Nats-Msg-Id"]),
+		Value:   msg.Data(),
+		Headers: h,
+		Native:  msg,
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		m.Offset = int64(meta.Sequence.Stream)
+		m.Time = meta.Timestamp
+	}
+	return m
+}
+
+// Introspect отдаёт состояние durable-консьюмера; партиция в JetStream одна — сам стрим.
+func (c *Consumer) Introspect(ctx context.Context) (mq.SourceStatus, error) {
+	info, err := c.cons.Info(ctx)
+	if err != nil {
+		return mq.SourceStatus{}, fmt.Errorf("consumer info: %w", err)
+	}
+	lag := int64(info.NumPending) + int64(info.NumAckPending)
+	return mq.SourceStatus{
+		Kind:  "nats",
+		Group: info.Name,
+		Partitions: []mq.PartitionStatus{{
+			Topic:     info.Stream,
+			Committed: int64(info.AckFloor.Stream),
+			HighWater: int64(info.Delivered.Stream) + int64(info.NumPending),
+			Lag:       lag,
+		}},
+		TotalLag: lag,
+		Client: map[string]any{
+			"ack_pending": info.NumAckPending,
+			"redelivered": info.NumRedelivered,
+			"waiting":     info.NumWaiting,
+		},
+	}, nil
+}
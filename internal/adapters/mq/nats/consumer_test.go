@@ -0,0 +1,86 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "nats server not ready")
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+func TestConsumer_FetchNackAck(t *testing.T) {
+	ns := runServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := NewConsumer(ctx, Config{
+		URL: ns.ClientURL(), Stream: "ORDERS", Subject: "orders", Durable: "test", AckWait: time.Second,
+		NakDelay: 10 * time.Millisecond, NakMaxDelay: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	nc, err := natsgo.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	msg := natsgo.NewMsg("orders")
+	msg.Data = []byte(`{"order_uid":"u1"}`)
+	msg.Header.Set("content-type", "application/json")
+	require.NoError(t, nc.PublishMsg(msg))
+
+	m, err := c.Fetch(ctx)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"order_uid":"u1"}`, string(m.Value))
+	require.Equal(t, "application/json", m.Headers["content-type"])
+	require.Equal(t, int64(1), m.Offset)
+
+	// после nack сообщение доставляется повторно
+	require.NoError(t, c.Nack(ctx, m))
+	m, err = c.Fetch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), m.Offset)
+	require.NoError(t, c.Ack(ctx, m))
+
+	// после term сообщение не передоставляется, как и после ack
+	msg.Data = []byte(`{"order_uid":"u2"}`)
+	require.NoError(t, nc.PublishMsg(msg))
+	m, err = c.Fetch(ctx)
+	require.NoError(t, err)
+	require.NoError(t, c.Term(ctx, m))
+
+	// ack и term уходят без ожидания ответа сервера
+	require.Eventually(t, func() bool {
+		st, err := c.Introspect(ctx)
+		return err == nil && st.TotalLag == 0 && st.Partitions[0].Committed == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// после ack и term очередь пуста
+	short, cancelShort := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancelShort()
+	_, err = c.Fetch(short)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := backoff{base: time.Second, max: 5 * time.Second}
+	for delivered, want := range map[uint64]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 100: 5 * time.Second} {
+		require.Equal(t, want, b.delay(delivered), delivered)
+	}
+}
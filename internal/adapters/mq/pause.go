@@ -0,0 +1,75 @@
+package mq
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+// Причины приостановки ingest. Цикл стоит, пока есть хотя бы одна.
+const (
+	PauseAdmin   = "admin"
+	PauseBreaker = "breaker"
+)
+
+// gate приостанавливает цикл, не закрывая источник: consumer group Kafka
+// продолжает слать heartbeat, ребалансировки не происходит.
+type gate struct {
+	mu      sync.Mutex
+	reasons map[string]struct{}
+	open    chan struct{} // закрыт, пока пауз нет
+}
+
+func newGate() *gate {
+	g := &gate{reasons: map[string]struct{}{}, open: make(chan struct{})}
+	close(g.open)
+	return g
+}
+
+func (g *gate) pause(reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.reasons) == 0 {
+		g.open = make(chan struct{})
+		metrics.IngestPaused.Set(1)
+	}
+	g.reasons[reason] = struct{}{}
+}
+
+func (g *gate) resume(reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.reasons[reason]; !ok {
+		return
+	}
+	delete(g.reasons, reason)
+	if len(g.reasons) == 0 {
+		close(g.open)
+		metrics.IngestPaused.Set(0)
+	}
+}
+
+func (g *gate) state() (bool, []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]string, 0, len(g.reasons))
+	for r := range g.reasons {
+		out = append(out, r)
+	}
+	sort.Strings(out)
+	return len(out) > 0, out
+}
+
+func (g *gate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	open := g.open
+	g.mu.Unlock()
+	select {
+	case <-open:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
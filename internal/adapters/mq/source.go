@@ -0,0 +1,35 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// Message — транспортно-независимое сообщение брокера.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+	// Native — исходное сообщение адаптера, нужно ему для ack/nack.
+	Native any
+}
+
+// MessageSource — источник сообщений для ingest-цикла (Kafka, NATS JetStream, ...).
+type MessageSource interface {
+	Fetch(ctx context.Context) (Message, error)
+	// Ack подтверждает обработку: сообщение больше не будет доставлено.
+	Ack(ctx context.Context, m Message) error
+	// Nack сообщает об ошибке обработки; повторная доставка зависит от брокера.
+	Nack(ctx context.Context, m Message) error
+	Close() error
+}
+
+// Terminator — источник, умеющий отвергнуть сообщение насовсем (NATS Term).
+// Без него отвергнутые сообщения подтверждаются через Ack.
+type Terminator interface {
+	Term(ctx context.Context, m Message) error
+}
@@ -0,0 +1,105 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type PartitionStatus struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	// Member — клиент consumer group, которому назначена партиция.
+	Member    string `json:"member,omitempty"`
+	Committed int64  `json:"committed_offset"`
+	HighWater int64  `json:"high_water_mark"`
+	Lag       int64  `json:"lag"`
+}
+
+// SourceStatus — состояние источника со стороны брокера.
+type SourceStatus struct {
+	Kind       string            `json:"kind"`
+	Group      string            `json:"group,omitempty"`
+	GroupState string            `json:"group_state,omitempty"`
+	Partitions []PartitionStatus `json:"partitions"`
+	TotalLag   int64             `json:"total_lag"`
+	// Client — показатели локального клиента (например, kafkago.ReaderStats).
+	Client map[string]any `json:"client,omitempty"`
+}
+
+// Introspector реализуют источники, умеющие рассказать о своём состоянии.
+type Introspector interface {
+	Introspect(ctx context.Context) (SourceStatus, error)
+}
+
+// Status — состояние ingest-цикла для админки.
+type Status struct {
+	Paused          bool          `json:"paused"`
+	PauseReasons    []string      `json:"pause_reasons,omitempty"`
+	Source          *SourceStatus `json:"source,omitempty"`
+	SourceError     string        `json:"source_error,omitempty"`
+	Processed       int64         `json:"processed"`
+	Failed          int64         `json:"failed"`
+	MessagesPerSec  float64       `json:"messages_per_sec"`
+	LastError       string        `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time    `json:"last_error_at,omitempty"`
+	LastIngestAt    *time.Time    `json:"last_ingest_at,omitempty"`
+	SinceLastIngest string        `json:"since_last_ingest,omitempty"`
+}
+
+const rateWindow = 60 // секунд
+
+// stats накапливает счётчики Ingestor; скорость — скользящее среднее за rateWindow.
+type stats struct {
+	mu           sync.Mutex
+	processed    int64
+	failed       int64
+	lastErr      string
+	lastErrAt    time.Time
+	lastIngestAt time.Time
+	buckets      [rateWindow]int64
+	stamps       [rateWindow]int64
+}
+
+func (s *stats) ingested(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed++
+	s.lastIngestAt = now
+	sec := now.Unix()
+	i := sec % rateWindow
+	if s.stamps[i] != sec {
+		s.stamps[i], s.buckets[i] = sec, 0
+	}
+	s.buckets[i]++
+}
+
+func (s *stats) failure(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+	s.lastErr, s.lastErrAt = err.Error(), now
+}
+
+func (s *stats) snapshot(now time.Time) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{Processed: s.processed, Failed: s.failed, LastError: s.lastErr}
+	var n int64
+	for i, stamp := range s.stamps {
+		if now.Unix()-stamp < rateWindow {
+			n += s.buckets[i]
+		}
+	}
+	st.MessagesPerSec = float64(n) / rateWindow
+	if !s.lastErrAt.IsZero() {
+		t := s.lastErrAt
+		st.LastErrorAt = &t
+	}
+	if !s.lastIngestAt.IsZero() {
+		t := s.lastIngestAt
+		st.LastIngestAt = &t
+		st.SinceLastIngest = now.Sub(t).Round(time.Millisecond).String()
+	}
+	return st
+}
@@ -0,0 +1,59 @@
+package mq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats_RateWindow(t *testing.T) {
+	var s stats
+	t0 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	require.Zero(t, s.snapshot(t0).MessagesPerSec)
+
+	// 60 сообщений в первую секунду окна, 120 — через 30 секунд
+	for range 60 {
+		s.ingested(t0)
+	}
+	for range 120 {
+		s.ingested(t0.Add(30 * time.Second))
+	}
+	st := s.snapshot(t0.Add(59 * time.Second))
+	require.Equal(t, int64(180), st.Processed)
+	require.InDelta(t, 3.0, st.MessagesPerSec, 1e-9)
+
+	// первая секунда вышла из окна
+	require.InDelta(t, 2.0, s.snapshot(t0.Add(60*time.Second)).MessagesPerSec, 1e-9)
+	// вышло всё окно
+	require.Zero(t, s.snapshot(t0.Add(91*time.Second)).MessagesPerSec)
+
+	// та же ячейка кольца через rateWindow секунд начинается заново
+	s.ingested(t0.Add(rateWindow * time.Second))
+	st = s.snapshot(t0.Add(rateWindow * time.Second))
+	require.InDelta(t, (120.0+1)/rateWindow, st.MessagesPerSec, 1e-9)
+}
+
+func TestStats_SinceLastIngest(t *testing.T) {
+	var s stats
+	t0 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	st := s.snapshot(t0)
+	require.Nil(t, st.LastIngestAt)
+	require.Empty(t, st.SinceLastIngest)
+	require.Nil(t, st.LastErrorAt)
+
+	s.ingested(t0)
+	s.ingested(t0.Add(2 * time.Second))
+	s.failure(t0.Add(3*time.Second), errors.New("boom"))
+
+	st = s.snapshot(t0.Add(3*time.Second + 1500*time.Microsecond))
+	require.Equal(t, t0.Add(2*time.Second), *st.LastIngestAt)
+	require.Equal(t, "1.002s", st.SinceLastIngest)
+	require.Equal(t, int64(2), st.Processed)
+	require.Equal(t, int64(1), st.Failed)
+	require.Equal(t, "boom", st.LastError)
+	require.Equal(t, t0.Add(3*time.Second), *st.LastErrorAt)
+}
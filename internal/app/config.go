@@ -4,17 +4,82 @@ import (
 	"time"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/oziev02/wb/internal/adapters/mq/kafka"
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/ratelimit"
 )
 
 type Config struct {
-	DBURL             string        `env:"DB_URL,required"`
-	HTTPAddr          string        `env:"HTTP_ADDR" envDefault:":8081"`
-	KafkaBrokers      []string      `env:"KAFKA_BROKERS" envSeparator:","`
-	KafkaTopic        string        `env:"KAFKA_TOPIC" envDefault:"orders"`
-	KafkaGroup        string        `env:"KAFKA_GROUP" envDefault:"orders-consumer"`
-	CacheCap          int           `env:"CACHE_CAP" envDefault:"10000"`
-	CacheTTL          time.Duration `env:"CACHE_TTL" envDefault:"30m"`
-	CacheRestoreLimit int           `env:"CACHE_RESTORE_LIMIT" envDefault:"10000"`
+	DBURL               string        `env:"DB_URL,required"`
+	HTTPAddr            string        `env:"HTTP_ADDR" envDefault:":8081"`
+	GRPCAddr            string        `env:"GRPC_ADDR" envDefault:":9090"`
+	BreakerEnabled      bool          `env:"DB_BREAKER_ENABLED" envDefault:"true"`
+	BreakerFailures     int           `env:"DB_BREAKER_FAILURES" envDefault:"5"`
+	BreakerSlowCall     time.Duration `env:"DB_BREAKER_SLOW_CALL" envDefault:"2s"`
+	BreakerOpenTimeout  time.Duration `env:"DB_BREAKER_OPEN_TIMEOUT" envDefault:"10s"`
+	MQSource            string        `env:"MQ_SOURCE" envDefault:"kafka"`
+	KafkaBrokers        []string      `env:"KAFKA_BROKERS" envSeparator:","`
+	KafkaTopic          string        `env:"KAFKA_TOPIC" envDefault:"orders"`
+	KafkaGroup          string        `env:"KAFKA_GROUP" envDefault:"orders-consumer"`
+	KafkaTLS            bool          `env:"KAFKA_TLS_ENABLED"`
+	KafkaTLSCAFile      string        `env:"KAFKA_TLS_CA_FILE"`
+	KafkaTLSCertFile    string        `env:"KAFKA_TLS_CERT_FILE"`
+	KafkaTLSKeyFile     string        `env:"KAFKA_TLS_KEY_FILE"`
+	KafkaTLSSkipVerify  bool          `env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+	KafkaSASLMechanism  string        `env:"KAFKA_SASL_MECHANISM"`
+	KafkaSASLUsername   string        `env:"KAFKA_SASL_USERNAME"`
+	KafkaSASLPassword   string        `env:"KAFKA_SASL_PASSWORD"`
+	SchemaStrict        bool          `env:"SCHEMA_STRICT"`
+	AvroSchemaDir       string        `env:"AVRO_SCHEMA_DIR"`
+	OutboxRelay         bool          `env:"OUTBOX_RELAY_ENABLED" envDefault:"true"`
+	OutboxTopic         string        `env:"OUTBOX_TOPIC" envDefault:"order-events"`
+	OutboxBatch         int           `env:"OUTBOX_BATCH" envDefault:"100"`
+	OutboxInterval      time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	NATSURL             string        `env:"NATS_URL" envDefault:"nats://localhost:4222"`
+	NATSStream          string        `env:"NATS_STREAM" envDefault:"ORDERS"`
+	NATSSubject         string        `env:"NATS_SUBJECT" envDefault:"orders"`
+	NATSDurable         string        `env:"NATS_DURABLE" envDefault:"orders-consumer"`
+	NATSAckWait         time.Duration `env:"NATS_ACK_WAIT" envDefault:"30s"`
+	NATSNakDelay        time.Duration `env:"NATS_NAK_DELAY" envDefault:"1s"`
+	NATSNakMaxDelay     time.Duration `env:"NATS_NAK_MAX_DELAY" envDefault:"1m"`
+	CacheCap            int           `env:"CACHE_CAP" envDefault:"10000"`
+	CacheTTL            time.Duration `env:"CACHE_TTL" envDefault:"30m"`
+	CacheRestoreLimit   int           `env:"CACHE_RESTORE_LIMIT" envDefault:"10000"`
+	CacheStaleGrace     time.Duration `env:"CACHE_STALE_GRACE" envDefault:"1h"`
+	CacheRefreshAhead   time.Duration `env:"CACHE_REFRESH_AHEAD" envDefault:"5m"`
+	CacheRefreshWorkers int           `env:"CACHE_REFRESH_WORKERS" envDefault:"4"`
+	AuthEnabled         bool          `env:"AUTH_ENABLED"`
+	AuthAPIKeys         []string      `env:"AUTH_API_KEYS" envSeparator:","`
+	AuthJWKSFiles       []string      `env:"AUTH_JWKS_FILES" envSeparator:","`
+	AuthJWTIssuer       string        `env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience     string        `env:"AUTH_JWT_AUDIENCE"`
+	AuthJWTRoleClaim    string        `env:"AUTH_JWT_ROLE_CLAIM" envDefault:"roles"`
+	AuthJWTLeeway       time.Duration `env:"AUTH_JWT_LEEWAY" envDefault:"30s"`
+	PIIPolicy           string        `env:"PII_POLICY"`
+	PIIKeyringFile      string        `env:"PII_KEYRING_FILE"`
+	PIIReencryptBatch   int           `env:"PII_REENCRYPT_BATCH" envDefault:"500"`
+	PIIReencryptEvery   time.Duration `env:"PII_REENCRYPT_INTERVAL" envDefault:"1m"`
+	RateLimitEnabled    bool          `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	RateLimitRoutes     string        `env:"RATE_LIMIT_ROUTES" envDefault:"/order/=10/s:20,/orders=5/s:10,/admin/=2/s:10"`
+	RateLimitNotFound   string        `env:"RATE_LIMIT_NOT_FOUND" envDefault:"20/m:30"`
+	RateLimitIdleTTL    time.Duration `env:"RATE_LIMIT_IDLE_TTL" envDefault:"10m"`
+	RateLimitTrustXFF   bool          `env:"RATE_LIMIT_TRUST_FORWARDED"`
+	RetentionDays       int           `env:"RETENTION_DAYS"`
+	RetentionMode       string        `env:"RETENTION_MODE" envDefault:"table"`
+	RetentionExportDir  string        `env:"RETENTION_EXPORT_DIR" envDefault:"./archive"`
+	RetentionBatch      int           `env:"RETENTION_BATCH" envDefault:"500"`
+	RetentionInterval   time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h"`
+	RetentionFallback   bool          `env:"RETENTION_ARCHIVE_FALLBACK" envDefault:"true"`
+	PartitionAhead      int           `env:"PARTITION_PRECREATE_MONTHS" envDefault:"3"`
+	PartitionDetachAge  int           `env:"PARTITION_DETACH_AFTER_MONTHS"`
+	PartitionInterval   time.Duration `env:"PARTITION_MAINTENANCE_INTERVAL" envDefault:"6h"`
+
+	// DBShards — имя шарда → DSN его базы; пусто — все заказы в DB_URL.
+	DBShards   map[string]string `env:"DB_SHARDS" envSeparator:";" envKeyValSeparator:"="`
+	DBShardMap string            `env:"DB_SHARD_MAP"`
+	// DBShardLegacyLookup — искать во всех шардах заказы, которых нет в order_shards.
+	DBShardLegacyLookup bool `env:"DB_SHARD_LEGACY_LOOKUP" envDefault:"true"`
 }
 
 func LoadConfig() (Config, error) {
@@ -22,3 +87,40 @@ func LoadConfig() (Config, error) {
 	err := env.Parse(&c)
 	return c, err
 }
+
+func (c Config) KafkaSecurity() kafka.Security {
+	return kafka.Security{
+		TLS:           c.KafkaTLS,
+		CAFile:        c.KafkaTLSCAFile,
+		CertFile:      c.KafkaTLSCertFile,
+		KeyFile:       c.KafkaTLSKeyFile,
+		SkipVerify:    c.KafkaTLSSkipVerify,
+		SASLMechanism: c.KafkaSASLMechanism,
+		Username:      c.KafkaSASLUsername,
+		Password:      c.KafkaSASLPassword,
+	}
+}
+
+func (c Config) AuthConfig() auth.Config {
+	return auth.Config{
+		Enabled:   c.AuthEnabled,
+		APIKeys:   c.AuthAPIKeys,
+		JWKSFiles: c.AuthJWKSFiles,
+		Issuer:    c.AuthJWTIssuer,
+		Audience:  c.AuthJWTAudience,
+		RoleClaim: c.AuthJWTRoleClaim,
+		Leeway:    c.AuthJWTLeeway,
+	}
+}
+
+func (c Config) RateLimitConfig() (ratelimit.Config, error) {
+	routes, err := ratelimit.ParseRoutes(c.RateLimitRoutes)
+	if err != nil {
+		return ratelimit.Config{}, err
+	}
+	notFound, err := ratelimit.ParseLimit(c.RateLimitNotFound)
+	if err != nil {
+		return ratelimit.Config{}, err
+	}
+	return ratelimit.Config{Routes: routes, NotFound: notFound, IdleTTL: c.RateLimitIdleTTL}, nil
+}
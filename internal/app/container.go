@@ -2,38 +2,267 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/adapters/db/breaker"
 	"github.com/oziev02/wb/internal/adapters/db/postgres"
+	"github.com/oziev02/wb/internal/adapters/db/shard"
+	"github.com/oziev02/wb/internal/adapters/mq/kafka"
 	"github.com/oziev02/wb/internal/cache"
+	"github.com/oziev02/wb/internal/domain"
+	"github.com/oziev02/wb/internal/fieldcrypt"
 	"github.com/oziev02/wb/internal/usecase"
 )
 
 type Container struct {
-	Cfg  Config
+	Cfg Config
+	// Pool — основная база (DB_URL). Без DB_SHARDS она же единственный шард,
+	// с DB_SHARDS в ней только справочник order_shards.
+	Pool  *pgxpool.Pool
+	Cache *cache.OrdersCache
+	Svc   *usecase.OrderService
+	// Breaker == nil, если DB_BREAKER_ENABLED=false.
+	Breaker *breaker.Repo
+	// Codecs разбирают входящие сообщения по заголовку content-type.
+	Codecs *codec.Registry
+	// Shards — базы с заказами и их фоновые задачи, по имени.
+	Shards []*Shard
+
+	publisher *kafka.Publisher
+	// keys == nil, если PII_KEYRING_FILE не задан.
+	keys *fieldcrypt.Keyring
+}
+
+// Shard — база с заказами. У каждой свои outbox, секции и архив, поэтому фоновые
+// задачи запускаются на каждый шард отдельно.
+type Shard struct {
+	Name string
 	Pool *pgxpool.Pool
-	Svc  *usecase.OrderService
+	Repo *postgres.OrderRepo
+	// Relay == nil, если OUTBOX_RELAY_ENABLED=false.
+	Relay *usecase.OutboxRelay
+	// Reencryptor == nil, если PII_KEYRING_FILE не задан (шифрование выключено).
+	Reencryptor *usecase.Reencryptor
+	// Retention == nil, если RETENTION_DAYS=0 (заказы хранятся бессрочно).
+	Retention *usecase.Retention
+	// Partitions создаёт и отсоединяет помесячные секции orders.
+	Partitions *usecase.PartitionMaintainer
 }
 
+// defaultShard — имя единственного шарда без DB_SHARDS.
+const defaultShard = "default"
+
 func NewContainer(ctx context.Context, cfg Config) (*Container, error) {
-	pool, err := pgxpool.New(ctx, cfg.DBURL)
+	// секция отсоединяется целиком: если она уйдёт раньше, чем retention перенесёт
+	// её заказы в архив, они пропадут из выдачи и из архива
+	if cfg.RetentionDays > 0 && cfg.PartitionDetachAge > 0 && cfg.PartitionDetachAge*28 <= cfg.RetentionDays+31 {
+		return nil, fmt.Errorf("PARTITION_DETACH_AFTER_MONTHS=%d would detach orders before RETENTION_DAYS=%d moves them",
+			cfg.PartitionDetachAge, cfg.RetentionDays)
+	}
+	pool, err := connect(ctx, cfg.DBURL)
+	if err != nil {
+		return nil, err
+	}
+	ct := &Container{Cfg: cfg, Pool: pool}
+
+	var repoOpts []postgres.Option
+	if cfg.RetentionDays > 0 && cfg.RetentionMode == "table" && cfg.RetentionFallback {
+		repoOpts = append(repoOpts, postgres.WithArchiveFallback())
+	}
+	if cfg.PIIKeyringFile != "" {
+		kr, err := fieldcrypt.LoadKeyring(cfg.PIIKeyringFile)
+		if err != nil {
+			ct.Close()
+			return nil, err
+		}
+		if !kr.HasIndex() {
+			// index ключует и отпечаток raw_digest: без него по SHA-256 открытого
+			// raw_json можно подбирать телефоны и адреса
+			ct.Close()
+			return nil, fmt.Errorf("pii keyring %s: \"index\" key is required", cfg.PIIKeyringFile)
+		}
+		repoOpts = append(repoOpts, postgres.WithKeyring(kr))
+		ct.keys = kr
+	}
+
+	if len(cfg.DBShards) == 0 {
+		ct.Shards = []*Shard{{Name: defaultShard, Pool: pool}}
+	} else {
+		names := slices.Sorted(maps.Keys(cfg.DBShards))
+		for _, name := range names {
+			p, err := connect(ctx, cfg.DBShards[name])
+			if err != nil {
+				ct.Close()
+				return nil, fmt.Errorf("shard %s: %w", name, err)
+			}
+			ct.Shards = append(ct.Shards, &Shard{Name: name, Pool: p})
+		}
+	}
+	for _, sh := range ct.Shards {
+		sh.Repo = postgres.NewOrderRepo(sh.Pool, repoOpts...)
+	}
+
+	// запросы субъектов идут мимо breaker: они редкие и не должны теряться молча
+	var (
+		repo     domain.OrderRepository
+		subjects usecase.SubjectStore
+	)
+	if len(cfg.DBShards) == 0 {
+		repo, subjects = ct.Shards[0].Repo, ct.Shards[0].Repo
+	} else {
+		keys, err := shard.ParseMap(cfg.DBShardMap, slices.Sorted(maps.Keys(cfg.DBShards)))
+		if err != nil {
+			ct.Close()
+			return nil, fmt.Errorf("DB_SHARD_MAP: %w", err)
+		}
+		stores := make(map[string]shard.Store, len(ct.Shards))
+		for _, sh := range ct.Shards {
+			stores[sh.Name] = sh.Repo
+		}
+		var shardOpts []shard.Option
+		if cfg.DBShardLegacyLookup {
+			shardOpts = append(shardOpts, shard.WithLegacyLookup())
+		}
+		sharded := shard.New(stores, keys, postgres.NewShardDirectory(pool), shardOpts...)
+		repo, subjects = sharded, sharded
+	}
+	if cfg.BreakerEnabled {
+		ct.Breaker = breaker.New(repo, breaker.Config{
+			Failures: cfg.BreakerFailures, SlowCall: cfg.BreakerSlowCall, OpenTimeout: cfg.BreakerOpenTimeout,
+		})
+		repo = ct.Breaker
+	}
+
+	ct.Cache = cache.NewOrdersCache(cfg.CacheCap, cfg.CacheTTL,
+		cache.WithRefreshAhead(cfg.CacheRefreshAhead, cfg.CacheRefreshWorkers, repo.GetByID),
+		cache.WithStaleGrace(cfg.CacheStaleGrace))
+	ct.Svc = usecase.NewOrderService(repo, ct.Cache, usecase.WithSubjectStore(subjects))
+
+	if err := ct.Svc.InitCache(cfg.CacheRestoreLimit); err != nil {
+		ct.Close()
+		return nil, fmt.Errorf("init cache: %w", err)
+	}
+
+	if ct.Codecs, err = NewCodecs(cfg); err != nil {
+		ct.Close()
+		return nil, fmt.Errorf("codecs: %w", err)
+	}
+
+	if cfg.OutboxRelay {
+		pub, err := kafka.NewPublisher(kafka.PublisherConfig{
+			Brokers: cfg.KafkaBrokers, Topic: cfg.OutboxTopic, Security: cfg.KafkaSecurity(),
+		})
+		if err != nil {
+			ct.Close()
+			return nil, fmt.Errorf("outbox publisher: %w", err)
+		}
+		ct.publisher = pub
+	}
+	for _, sh := range ct.Shards {
+		if err := ct.addJobs(sh); err != nil {
+			ct.Close()
+			return nil, fmt.Errorf("shard %s: %w", sh.Name, err)
+		}
+	}
+	return ct, nil
+}
+
+func connect(ctx context.Context, url string) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("pgxpool: %w", err)
 	}
 	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("db ping: %w", err)
 	}
+	return pool, nil
+}
 
-	repo := postgres.NewOrderRepo(pool)
+func (c *Container) addJobs(sh *Shard) error {
+	cfg := c.Cfg
+	sh.Partitions = usecase.NewPartitionMaintainer(postgres.NewPartitioner(sh.Pool),
+		cfg.PartitionAhead, cfg.PartitionDetachAge, cfg.PartitionInterval)
+	if cfg.PIIKeyringFile != "" {
+		sh.Reencryptor = usecase.NewReencryptor(sh.Repo, sh.Name, cfg.PIIReencryptBatch, cfg.PIIReencryptEvery)
+	}
+	dir := cfg.RetentionExportDir
+	if len(c.Shards) > 1 {
+		dir = filepath.Join(dir, sh.Name)
+	}
+	archiver, err := newArchiver(sh.Pool, cfg, dir)
+	if err != nil {
+		return err
+	}
+	if archiver != nil {
+		sh.Retention = usecase.NewRetention(archiver, sh.Name, time.Duration(cfg.RetentionDays)*24*time.Hour,
+			cfg.RetentionBatch, cfg.RetentionInterval)
+	}
+	if c.publisher != nil {
+		sh.Relay = usecase.NewOutboxRelay(postgres.NewOutboxRepo(sh.Pool, c.keys), c.publisher, cfg.OutboxBatch, cfg.OutboxInterval)
+	}
+	return nil
+}
 
-	c := cache.NewOrdersCache(cfg.CacheCap, cfg.CacheTTL)
-	svc := usecase.NewOrderService(repo, c)
+// Ping проверяет основную базу и базы всех шардов.
+func (c *Container) Ping(ctx context.Context) error {
+	if err := c.Pool.Ping(ctx); err != nil {
+		return err
+	}
+	for _, sh := range c.Shards {
+		if sh.Pool == c.Pool {
+			continue
+		}
+		if err := sh.Pool.Ping(ctx); err != nil {
+			return fmt.Errorf("shard %s: %w", sh.Name, err)
+		}
+	}
+	return nil
+}
 
-	if err := svc.InitCache(cfg.CacheRestoreLimit); err != nil {
-		return nil, fmt.Errorf("init cache: %w", err)
+// newArchiver — nil, если срок хранения не задан.
+func newArchiver(pool *pgxpool.Pool, cfg Config, dir string) (*postgres.Archiver, error) {
+	if cfg.RetentionDays <= 0 {
+		return nil, nil
 	}
+	switch cfg.RetentionMode {
+	case "table":
+		return postgres.NewTableArchiver(pool), nil
+	case "files":
+		// выгрузка недоступна экспорту и стиранию по запросу субъекта и перешифрованию:
+		// с ними персональные данные остались бы в файлах без учёта
+		if cfg.AuthEnabled || cfg.PIIKeyringFile != "" {
+			return nil, errors.New("RETENTION_MODE=files keeps personal data outside /admin/subjects/* and re-encryption: " +
+				"use RETENTION_MODE=table with AUTH_ENABLED or PII_KEYRING_FILE")
+		}
+		return postgres.NewFileArchiver(pool, dir)
+	default:
+		return nil, fmt.Errorf("RETENTION_MODE=%q: want table or files", cfg.RetentionMode)
+	}
+}
 
-	return &Container{Cfg: cfg, Pool: pool, Svc: svc}, nil
+func (c *Container) Close() {
+	if c.publisher != nil {
+		if err := c.publisher.Close(); err != nil {
+			log.Printf("outbox publisher close: %v", err)
+		}
+	}
+	if c.Cache != nil {
+		c.Cache.Close()
+	}
+	for _, sh := range c.Shards {
+		if sh.Pool != c.Pool {
+			sh.Pool.Close()
+		}
+	}
+	c.Pool.Close()
 }
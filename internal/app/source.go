@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/oziev02/wb/internal/adapters/codec"
+	"github.com/oziev02/wb/internal/adapters/db/breaker"
+	"github.com/oziev02/wb/internal/adapters/mq"
+	"github.com/oziev02/wb/internal/adapters/mq/kafka"
+	"github.com/oziev02/wb/internal/adapters/mq/nats"
+)
+
+// NewMessageSource выбирает брокер по MQ_SOURCE.
+func NewMessageSource(ctx context.Context, cfg Config) (mq.MessageSource, error) {
+	switch cfg.MQSource {
+	case "kafka":
+		return kafka.NewConsumer(kafka.Config{
+			Brokers: cfg.KafkaBrokers, Topic: cfg.KafkaTopic, GroupID: cfg.KafkaGroup,
+			Security: cfg.KafkaSecurity(),
+		})
+	case "nats":
+		return nats.NewConsumer(ctx, nats.Config{
+			URL: cfg.NATSURL, Stream: cfg.NATSStream, Subject: cfg.NATSSubject,
+			Durable: cfg.NATSDurable, AckWait: cfg.NATSAckWait,
+			NakDelay: cfg.NATSNakDelay, NakMaxDelay: cfg.NATSNakMaxDelay,
+		})
+	default:
+		return nil, fmt.Errorf("unknown MQ_SOURCE %q", cfg.MQSource)
+	}
+}
+
+// defaultAvroSchemaDir — схемы Avro, если AVRO_SCHEMA_DIR не задан.
+const defaultAvroSchemaDir = "./schemas/avro/order"
+
+// NewCodecs — JSON по умолчанию (сообщения без content-type), Protobuf и Avro по заголовку.
+// Avro включается, только если задан AVRO_SCHEMA_DIR или есть каталог по умолчанию;
+// заданный, но недоступный каталог — ошибка.
+func NewCodecs(cfg Config) (*codec.Registry, error) {
+	codecs := []codec.Codec{codec.Protobuf{}}
+	dir := cfg.AvroSchemaDir
+	if dir == "" {
+		if _, err := os.Stat(defaultAvroSchemaDir); err == nil {
+			dir = defaultAvroSchemaDir
+		}
+	}
+	if dir != "" {
+		reg, err := codec.NewFileSchemaRegistry(dir)
+		if err != nil {
+			return nil, fmt.Errorf("avro schemas: %w", err)
+		}
+		codecs = append(codecs, codec.NewAvro(reg))
+	} else {
+		log.Printf("avro codec disabled: no schemas in %s and AVRO_SCHEMA_DIR is not set", defaultAvroSchemaDir)
+	}
+	return codec.NewRegistry(codec.JSON{Strict: cfg.SchemaStrict}, codecs...), nil
+}
+
+// PauseOnBreaker останавливает ingest, пока breaker БД открыт. Через OpenTimeout
+// приём возобновляется, и первый же UpsertOrder становится пробным вызовом:
+// при неудаче breaker снова размыкается и ingest снова встаёт на паузу.
+func PauseOnBreaker(b *breaker.Repo, ing *mq.Ingestor, openTimeout time.Duration) {
+	b.OnStateChange(func(from, to breaker.State) {
+		log.Printf("[breaker] %s -> %s", from, to)
+		switch to {
+		case breaker.Open:
+			ing.Pause(mq.PauseBreaker)
+			time.AfterFunc(openTimeout, func() { ing.Resume(mq.PauseBreaker) })
+		case breaker.Closed:
+			ing.Resume(mq.PauseBreaker)
+		case breaker.HalfOpen:
+		}
+	})
+}
+
+// HealthOnBreaker переводит gRPC health сервиса в NOT_SERVING, пока breaker БД открыт.
+func HealthOnBreaker(b *breaker.Repo, hs *health.Server, service string) {
+	b.OnStateChange(func(_, to breaker.State) {
+		st := healthpb.HealthCheckResponse_SERVING
+		if to == breaker.Open {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		hs.SetServingStatus(service, st)
+	})
+}
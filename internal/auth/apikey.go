@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+type apiKey struct {
+	name   string
+	role   Role
+	digest [sha256.Size]byte
+}
+
+// parseAPIKeys разбирает AUTH_API_KEYS: "name:role:key,...".
+// Ключ может содержать ':'; имя и роль — нет.
+func parseAPIKeys(specs []string) ([]apiKey, error) {
+	keys := make([]apiKey, 0, len(specs))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("api key %q: want name:role:key", parts[0])
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", parts[0], err)
+		}
+		keys = append(keys, apiKey{name: parts[0], role: role, digest: sha256.Sum256([]byte(parts[2]))})
+	}
+	return keys, nil
+}
+
+// matchAPIKey сравнивает дайджесты за постоянное время и всегда проходит весь список.
+func matchAPIKey(keys []apiKey, presented string) (apiKey, bool) {
+	d := sha256.Sum256([]byte(presented))
+	var found apiKey
+	ok := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(d[:], k.digest[:]) == 1 {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrNoCredentials — запрос без API-ключа и без Bearer-токена.
+	ErrNoCredentials = errors.New("authentication required")
+	// ErrInvalidCredentials — ключ неизвестен, токен не прошёл проверку или в нём нет роли.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type Config struct {
+	// Enabled = false: каждый запрос выполняется от анонимного admin (как до появления аутентификации).
+	Enabled bool
+	// APIKeys — статические ключи "name:role:key".
+	APIKeys []string
+	// JWKSFiles — локальные файлы JWKS с ключами проверки подписи JWT.
+	JWKSFiles []string
+	Issuer    string
+	Audience  string
+	// RoleClaim — claim с ролью: строка или массив строк (берётся старшая известная роль).
+	RoleClaim string
+	Leeway    time.Duration
+}
+
+type Authenticator struct {
+	enabled   bool
+	keys      []apiKey
+	jwks      []verifyKey
+	parser    *jwt.Parser
+	roleClaim string
+}
+
+// асимметричные алгоритмы; HS* и none не принимаются никогда.
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{enabled: cfg.Enabled, roleClaim: cfg.RoleClaim}
+	if !cfg.Enabled {
+		return a, nil
+	}
+	var err error
+	if a.keys, err = parseAPIKeys(cfg.APIKeys); err != nil {
+		return nil, err
+	}
+	if a.jwks, err = loadJWKS(cfg.JWKSFiles); err != nil {
+		return nil, err
+	}
+	if len(a.keys) == 0 && len(a.jwks) == 0 {
+		return nil, errors.New("auth enabled but neither API keys nor JWKS files are configured")
+	}
+	if a.roleClaim == "" {
+		a.roleClaim = "roles"
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(jwtMethods), jwt.WithExpirationRequired(), jwt.WithLeeway(cfg.Leeway)}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+func (a *Authenticator) Enabled() bool { return a.enabled }
+
+// Authenticate проверяет X-API-Key или Authorization: Bearer <jwt>.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	return a.AuthenticateCredentials(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+}
+
+// AuthenticateCredentials — Authenticate для транспортов без http.Request (gRPC metadata):
+// значения заголовков X-API-Key и Authorization, пустые — если их нет.
+func (a *Authenticator) AuthenticateCredentials(apiKey, authorization string) (Principal, error) {
+	if !a.enabled {
+		return Principal{Subject: "anonymous", Role: RoleAdmin, Method: "anonymous"}, nil
+	}
+	if apiKey != "" {
+		return a.apiKey(apiKey)
+	}
+	if h := authorization; h != "" {
+		scheme, tok, _ := strings.Cut(h, " ")
+		if !strings.EqualFold(scheme, "Bearer") || tok == "" {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return a.jwt(strings.TrimSpace(tok))
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+// AuthenticateToken — для клиентов, не умеющих ставить заголовки (EventSource, WebSocket в браузере):
+// токен из query-параметра, JWT или API-ключ.
+func (a *Authenticator) AuthenticateToken(tok string) (Principal, error) {
+	if !a.enabled {
+		return Principal{Subject: "anonymous", Role: RoleAdmin, Method: "anonymous"}, nil
+	}
+	if strings.Count(tok, ".") == 2 {
+		return a.jwt(tok)
+	}
+	return a.apiKey(tok)
+}
+
+func (a *Authenticator) apiKey(key string) (Principal, error) {
+	k, ok := matchAPIKey(a.keys, key)
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: k.name, Role: k.role, Method: "api_key"}, nil
+}
+
+func (a *Authenticator) jwt(raw string) (Principal, error) {
+	if len(a.jwks) == 0 {
+		return Principal{}, ErrInvalidCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyFor); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	role := highestRole(claims[a.roleClaim])
+	if role == RoleNone {
+		return Principal{}, fmt.Errorf("%w: no known role in claim %q", ErrInvalidCredentials, a.roleClaim)
+	}
+	sub, _ := claims.GetSubject()
+	return Principal{Subject: sub, Role: role, Method: "jwt"}, nil
+}
+
+func (a *Authenticator) keyFor(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	alg := t.Method.Alg()
+	for _, k := range a.jwks {
+		if (kid != "" && k.kid != kid) || (k.alg != "" && k.alg != alg) || !algFits(alg, k.key) {
+			continue
+		}
+		return k.key, nil
+	}
+	return nil, fmt.Errorf("no verification key for kid %q alg %s", kid, alg)
+}
+
+func algFits(alg string, key any) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func highestRole(v any) Role {
+	var names []string
+	switch t := v.(type) {
+	case string:
+		names = strings.Fields(strings.ReplaceAll(t, ",", " "))
+	case []any:
+		for _, el := range t {
+			if s, ok := el.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	best := RoleNone
+	for _, n := range names {
+		if r, err := ParseRole(n); err == nil && r > best {
+			best = r
+		}
+	}
+	return best
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate_APIKeys(t *testing.T) {
+	a, err := New(Config{Enabled: true, APIKeys: []string{"ui:viewer:k1", "ops:admin:k:with:colons"}})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/orders", nil)
+	_, err = a.Authenticate(r)
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	r.Header.Set(APIKeyHeader, "k1")
+	p, err := a.Authenticate(r)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "ui", Role: RoleViewer, Method: "api_key"}, p)
+
+	p, err = a.AuthenticateToken("k:with:colons")
+	require.NoError(t, err)
+	require.Equal(t, RoleAdmin, p.Role)
+
+	r.Header.Set(APIKeyHeader, "nope")
+	_, err = a.Authenticate(r)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = New(Config{Enabled: true, APIKeys: []string{"x:root:k"}})
+	require.Error(t, err)
+	_, err = New(Config{Enabled: true})
+	require.Error(t, err)
+
+	off, err := New(Config{})
+	require.NoError(t, err)
+	p, err = off.Authenticate(httptest.NewRequest("GET", "/orders", nil))
+	require.NoError(t, err)
+	require.Equal(t, RoleAdmin, p.Role)
+}
+
+func TestAuthenticate_JWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "r1", "use": "sig", "alg": "RS256",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "OKP", "kid": "e1", "crv": "Ed25519", "x": b64(edPub)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	a, err := New(Config{Enabled: true, JWKSFiles: []string{path}, Issuer: "sso", Audience: "wb"})
+	require.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	claims := func(roles any, exp time.Duration) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "alice", "iss": "sso", "aud": "wb", "exp": time.Now().Add(exp).Unix(), "roles": roles}
+	}
+
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(jwt.SigningMethodRS256, "r1", rsaKey, claims([]any{"viewer", "support", "unknown"}, time.Hour)))
+	p, err := a.Authenticate(r)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "alice", Role: RoleSupport, Method: "jwt"}, p)
+
+	p, err = a.AuthenticateToken(sign(jwt.SigningMethodEdDSA, "e1", edKey, claims("admin", time.Hour)))
+	require.NoError(t, err)
+	require.Equal(t, RoleAdmin, p.Role)
+
+	rejected := map[string]string{
+		"expired":    sign(jwt.SigningMethodRS256, "r1", rsaKey, claims("admin", -time.Hour)),
+		"no role":    sign(jwt.SigningMethodRS256, "r1", rsaKey, claims("root", time.Hour)),
+		"hmac":       sign(jwt.SigningMethodHS256, "r1", []byte("secret"), claims("admin", time.Hour)),
+		"wrong kid":  sign(jwt.SigningMethodEdDSA, "r1", edKey, claims("admin", time.Hour)),
+		"wrong iss":  sign(jwt.SigningMethodRS256, "r1", rsaKey, jwt.MapClaims{"iss": "other", "aud": "wb", "exp": time.Now().Add(time.Hour).Unix(), "roles": "admin"}),
+		"enc key":    sign(jwt.SigningMethodRS256, "enc", rsaKey, claims("admin", time.Hour)),
+		"not bearer": "",
+	}
+	for name, tok := range rejected {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/orders", nil)
+			if tok == "" {
+				r.Header.Set("Authorization", "Basic dTpw")
+			} else {
+				r.Header.Set("Authorization", "Bearer "+tok)
+			}
+			_, err := a.Authenticate(r)
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+}
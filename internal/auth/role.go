@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Role упорядочены: каждая следующая включает права предыдущей.
+type Role int
+
+const (
+	RoleNone Role = iota
+	// RoleViewer — чтение заказов; контакты покупателя по умолчанию замаскированы (см. redact).
+	RoleViewer
+	// RoleSupport — чтение заказов, по умолчанию с контактами покупателя.
+	RoleSupport
+	// RoleAdmin — всё, включая /admin/.
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleSupport:
+		return "support"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "support":
+		return RoleSupport, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown role %q", s)
+	}
+}
+
+// Principal — аутентифицированный вызывающий.
+type Principal struct {
+	Subject string
+	Role    Role
+	// Method — как он аутентифицирован: api_key, jwt или anonymous (аутентификация выключена).
+	Method string
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"log"
+	"sync"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2/expirable"
@@ -8,19 +10,164 @@ import (
 	"github.com/oziev02/wb/internal/domain"
 )
 
+// Loader перечитывает заказ из первоисточника (обычно repo.GetByID).
+type Loader func(id string) (domain.Order, bool, error)
+
+type entry struct {
+	order     domain.Order
+	expiresAt time.Time
+}
+
 // LRU + TTL. Решает проблему OOM при бесконечном росте ключей.
+// Опционально refresh-ahead: горячие записи перечитываются в фоне до истечения TTL,
+// и stale-grace: вытесненные записи ещё какое-то время доступны через GetStale.
 type OrdersCache struct {
-	l *lru.LRU[string, domain.Order]
+	l     *lru.LRU[string, entry]
+	grace *lru.LRU[string, domain.Order]
+	ttl   time.Duration
+	now   func() time.Time
+
+	ahead    time.Duration
+	workers  int
+	loader   Loader
+	queue    chan string
+	inflight sync.Map
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+
+	graceTTL time.Duration
+}
+
+type Option func(*OrdersCache)
+
+// WithRefreshAhead включает асинхронное обновление записей, прочитанных
+// менее чем за window до истечения TTL. Перезагрузку выполняют workers горутин.
+func WithRefreshAhead(window time.Duration, workers int, load Loader) Option {
+	return func(c *OrdersCache) {
+		c.ahead = window
+		c.workers = workers
+		c.loader = load
+	}
+}
+
+// WithStaleGrace сохраняет вытесненные записи ещё на grace, чтобы отдать их,
+// если БД недоступна (stale-while-error).
+func WithStaleGrace(grace time.Duration) Option {
+	return func(c *OrdersCache) { c.graceTTL = grace }
+}
+
+func NewOrdersCache(cap int, ttl time.Duration, opts ...Option) *OrdersCache {
+	c := &OrdersCache{
+		ttl:  ttl,
+		now:  time.Now,
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	var onEvict lru.EvictCallback[string, entry]
+	if c.graceTTL > 0 {
+		c.grace = lru.NewLRU[string, domain.Order](cap, nil, c.graceTTL)
+		onEvict = func(id string, e entry) { c.grace.Add(id, e.order) }
+	}
+	c.l = lru.NewLRU[string, entry](cap, onEvict, ttl)
+	if c.refreshEnabled() {
+		// очередь ограничена: при переполнении обновление просто пропускается,
+		// запись доживёт до TTL и будет загружена обычным промахом.
+		c.queue = make(chan string, c.workers*16)
+		for i := 0; i < c.workers; i++ {
+			c.wg.Add(1)
+			go c.refreshWorker()
+		}
+	}
+	return c
+}
+
+func (c *OrdersCache) Get(id string) (domain.Order, bool) {
+	e, ok := c.l.Get(id)
+	if !ok {
+		return domain.Order{}, false
+	}
+	if c.refreshEnabled() && e.expiresAt.Sub(c.now()) < c.ahead {
+		c.scheduleRefresh(id)
+	}
+	return e.order, true
+}
+
+func (c *OrdersCache) Set(o domain.Order) {
+	c.l.Add(o.OrderUID, entry{order: o, expiresAt: c.now().Add(c.ttl)})
+	if c.grace != nil {
+		c.grace.Remove(o.OrderUID)
+	}
+}
+
+// GetStale ищет запись среди недавно вытесненных (истёк TTL или вытеснена по LRU).
+func (c *OrdersCache) GetStale(id string) (domain.Order, bool) {
+	if c.grace == nil {
+		return domain.Order{}, false
+	}
+	return c.grace.Get(id)
 }
 
-func NewOrdersCache(cap int, ttl time.Duration) *OrdersCache {
-	return &OrdersCache{l: lru.NewLRU[string, domain.Order](cap, nil, ttl)}
+// Len — число заказов, которые кэш может отдать, включая вытесненные в stale-grace.
+func (c *OrdersCache) Len() int {
+	n := c.l.Len()
+	if c.grace != nil {
+		n += c.grace.Len()
+	}
+	return n
 }
 
-func (c *OrdersCache) Get(id string) (domain.Order, bool) { return c.l.Get(id) }
-func (c *OrdersCache) Set(o domain.Order)                 { c.l.Add(o.OrderUID, o) }
 func (c *OrdersCache) BulkSet(orders []domain.Order) {
 	for _, o := range orders {
 		c.Set(o)
 	}
 }
+
+// Close останавливает воркеров refresh-ahead. Повторный вызов безопасен.
+func (c *OrdersCache) Close() {
+	c.once.Do(func() { close(c.done) })
+	c.wg.Wait()
+}
+
+func (c *OrdersCache) refreshEnabled() bool {
+	return c.ahead > 0 && c.ttl > 0 && c.workers > 0 && c.loader != nil
+}
+
+func (c *OrdersCache) scheduleRefresh(id string) {
+	if _, busy := c.inflight.LoadOrStore(id, struct{}{}); busy {
+		return
+	}
+	select {
+	case c.queue <- id:
+	case <-c.done:
+		c.inflight.Delete(id)
+	default:
+		c.inflight.Delete(id)
+	}
+}
+
+func (c *OrdersCache) refreshWorker() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		case id := <-c.queue:
+			c.refresh(id)
+		}
+	}
+}
+
+func (c *OrdersCache) refresh(id string) {
+	defer c.inflight.Delete(id)
+	o, ok, err := c.loader(id)
+	if err != nil {
+		log.Printf("[cache] refresh %s: %v", id, err)
+		return
+	}
+	if ok {
+		c.Set(o)
+	}
+}
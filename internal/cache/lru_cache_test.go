@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+func TestGet_RefreshAheadNearExpiry(t *testing.T) {
+	loaded := make(chan string, 1)
+	load := func(id string) (domain.Order, bool, error) {
+		loaded <- id
+		return domain.Order{OrderUID: id, TrackNumber: "fresh"}, true, nil
+	}
+	c := NewOrdersCache(10, time.Hour, WithRefreshAhead(10*time.Minute, 1, load))
+	defer c.Close()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.Set(domain.Order{OrderUID: "u1", TrackNumber: "old"})
+
+	// далеко от истечения — обновление не планируется
+	o, ok := c.Get("u1")
+	require.True(t, ok)
+	require.Equal(t, "old", o.TrackNumber)
+	require.Empty(t, loaded)
+
+	// внутри окна refresh-ahead — отдаём старую копию и обновляем в фоне
+	now = now.Add(55 * time.Minute)
+	o, ok = c.Get("u1")
+	require.True(t, ok)
+	require.Equal(t, "old", o.TrackNumber)
+
+	select {
+	case id := <-loaded:
+		require.Equal(t, "u1", id)
+	case <-time.After(time.Second):
+		t.Fatal("refresh was not scheduled")
+	}
+	require.Eventually(t, func() bool {
+		o, _ := c.Get("u1")
+		return o.TrackNumber == "fresh"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestLen_CountsStaleGrace(t *testing.T) {
+	c := NewOrdersCache(1, time.Hour, WithStaleGrace(time.Hour))
+	defer c.Close()
+	require.Zero(t, c.Len())
+
+	c.Set(domain.Order{OrderUID: "u1"})
+	c.Set(domain.Order{OrderUID: "u2"}) // u1 вытеснен, но ещё доступен через GetStale
+	require.Equal(t, 2, c.Len())
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventOrderCreated = "order.created"
+	EventOrderUpdated = "order.updated"
+	// EventOrderIngested — заказ принят сервисом (живой поток; создан он или изменён, не различается).
+	EventOrderIngested = "order.ingested"
+)
+
+// OrderEvent — доменное событие для внешних потребителей.
+type OrderEvent struct {
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      Order     `json:"order"`
+}
+
+func NewOrderEvent(typ string, o Order, at time.Time) OrderEvent {
+	return OrderEvent{Type: typ, OrderUID: o.OrderUID, OccurredAt: at, Order: o}
+}
+
+func (e OrderEvent) Payload() ([]byte, error) { return json.Marshal(e) }
+
+// OutboxRecord — сохранённое в outbox событие, ожидающее публикации.
+type OutboxRecord struct {
+	ID      int64
+	Type    string
+	Key     string
+	Payload []byte
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maskFill = "***"
+
+// MaskPhone оставляет код страны и две последние цифры: +79991234545 → +7***45.
+func MaskPhone(s string) string {
+	var digits []rune
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) < 5 {
+		return MaskText(s)
+	}
+	prefix := string(digits[0])
+	if strings.HasPrefix(strings.TrimSpace(s), "+") {
+		prefix = "+" + prefix
+	}
+	return prefix + maskFill + string(digits[len(digits)-2:])
+}
+
+// MaskEmail оставляет первую букву ящика и домен: alice@mail.ru → a***@mail.ru.
+func MaskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return MaskText(s)
+	}
+	return MaskText(s[:at]) + s[at:]
+}
+
+// MaskName маскирует каждое слово: Ivan Ivanov → I*** I***.
+func MaskName(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = MaskText(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskText оставляет только первый символ.
+func MaskText(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r) + maskFill
+}
+
+// String маскирует контактные данные, чтобы заказ, попавший в лог через %v, не раскрывал их.
+func (d Delivery) String() string {
+	return fmt.Sprintf("{%s %s %s %s %s %s %s}",
+		MaskName(d.Name), MaskPhone(d.Phone), d.Zip, d.City, MaskText(d.Address), d.Region, MaskEmail(d.Email))
+}
+
+// GoString — то же для %#v.
+func (d Delivery) GoString() string { return "domain.Delivery" + d.String() }
@@ -11,6 +11,7 @@ import (
 )
 
 type Order struct {
+	SchemaVersion     int       `json:"schema_version,omitempty"`
 	OrderUID          string    `json:"order_uid"`
 	TrackNumber       string    `json:"track_number"`
 	Entry             string    `json:"entry"`
@@ -25,6 +26,8 @@ type Order struct {
 	SmID              int       `json:"sm_id"`
 	DateCreated       time.Time `json:"date_created"`
 	OofShard          string    `json:"oof_shard"`
+	// UpdatedAt — время последнего изменения в хранилище; в raw_json не пишется.
+	UpdatedAt time.Time `json:"-"`
 }
 
 type Delivery struct {
@@ -66,28 +69,38 @@ type Item struct {
 
 var v = validator.New()
 
+// ErrInvalidOrder оборачивает все ошибки Validate: errors.Is(err, ErrInvalidOrder).
+var ErrInvalidOrder = errors.New("invalid order")
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidOrder}, args...)...)
+}
+
 // структурная и содержательная валидация.
 func (o *Order) Validate() error {
+	if o.SchemaVersion > CurrentSchemaVersion {
+		return invalid("unsupported schema_version %d", o.SchemaVersion)
+	}
 	if o.OrderUID == "" {
-		return errors.New("order_uid is required")
+		return invalid("order_uid is required")
 	}
 	if o.TrackNumber == "" {
-		return errors.New("track_number is required")
+		return invalid("track_number is required")
 	}
 	if len(o.Items) == 0 {
-		return errors.New("items must not be empty")
+		return invalid("items must not be empty")
 	}
 	if o.Payment.Amount < 0 || o.Payment.GoodsTotal < 0 || o.Payment.DeliveryCost < 0 {
-		return fmt.Errorf("amount fields must be >= 0")
+		return invalid("amount fields must be >= 0")
 	}
 	if o.Delivery.Email != "" {
 		if _, err := mail.ParseAddress(o.Delivery.Email); err != nil {
-			return fmt.Errorf("invalid delivery.email")
+			return invalid("invalid delivery.email")
 		}
 	}
 	// опционально: ограничить Locale
 	if o.Locale != "" && o.Locale != "ru" && o.Locale != "en" {
-		return fmt.Errorf("unsupported locale")
+		return invalid("unsupported locale")
 	}
 	return nil
 }
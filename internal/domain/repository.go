@@ -1,7 +1,41 @@
 package domain
 
+import (
+	"errors"
+	"time"
+)
+
 type OrderRepository interface {
-	UpsertOrder(o Order) error
+	UpsertOrder(o Order) (UpsertResult, error)
 	GetByID(orderUID string) (Order, bool, error)
 	LoadAll(limit int) ([]Order, error)
+	Search(f OrderFilter) ([]Order, error)
+}
+
+// UpsertResult — итог UpsertOrder.
+type UpsertResult struct {
+	// Changed — заказ записан: новый или с другим содержимым. false — повторная
+	// доставка без изменений, сохранённая версия не тронута.
+	Changed bool
+	// UpdatedAt — updated_at сохранённой версии.
+	UpdatedAt time.Time
 }
+
+// OrderFilter — условия поиска; пустые поля не ограничивают выборку.
+// CreatedFrom включительно, CreatedTo — нет.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Entry           string
+	TrackNumber     string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Limit           int
+}
+
+var (
+	// ErrUnavailable — хранилище временно недоступно (например, открыт circuit breaker).
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrNotFound — заказа нет ни в кэше, ни в хранилище.
+	ErrNotFound = errors.New("order not found")
+)
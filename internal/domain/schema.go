@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentSchemaVersion — версия формы Order, с которой работает сервис.
+// Сообщения без schema_version считаются версией 0 (до введения версионирования).
+const CurrentSchemaVersion = 1
+
+// Upcaster переводит документ версии N в версию N+1, изменяя его на месте.
+type Upcaster func(doc map[string]any) error
+
+// Upcasters — цепочка преобразований старых версий payload к текущей.
+type Upcasters struct {
+	current int
+	steps   map[int]Upcaster
+}
+
+func NewUpcasters(current int) *Upcasters {
+	return &Upcasters{current: current, steps: map[int]Upcaster{}}
+}
+
+// Register задаёт преобразование from -> from+1.
+func (u *Upcasters) Register(from int, fn Upcaster) { u.steps[from] = fn }
+
+// DefaultUpcasters используются при приёме сообщений и чтении raw_json.
+var DefaultUpcasters = NewUpcasters(CurrentSchemaVersion)
+
+func init() {
+	// v0 -> v1: форма не менялась, появилось только поле schema_version.
+	DefaultUpcasters.Register(0, func(map[string]any) error { return nil })
+}
+
+// DecodeOrderJSON разбирает JSON заказа любой поддерживаемой версии через DefaultUpcasters.
+func DecodeOrderJSON(raw []byte, strict bool) (Order, error) {
+	return DefaultUpcasters.Decode(raw, strict)
+}
+
+// Decode приводит payload к текущей версии и разбирает его в Order.
+// В strict-режиме неизвестные поля неверсионированных сообщений — ошибка,
+// а не молчаливая потеря данных.
+func (u *Upcasters) Decode(raw []byte, strict bool) (Order, error) {
+	var head struct {
+		Version *int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return Order{}, err
+	}
+	ver := 0
+	if head.Version != nil {
+		ver = *head.Version
+	}
+	switch {
+	case ver > u.current:
+		return Order{}, fmt.Errorf("unsupported schema_version %d (current %d)", ver, u.current)
+	case ver < u.current:
+		up, err := u.upcast(raw, ver)
+		if err != nil {
+			return Order{}, err
+		}
+		raw = up
+	}
+
+	var o Order
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if strict && head.Version == nil {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&o); err != nil {
+		return Order{}, err
+	}
+	return o, nil
+}
+
+func (u *Upcasters) upcast(raw []byte, from int) ([]byte, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for v := from; v < u.current; v++ {
+		step, ok := u.steps[v]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster from schema_version %d", v)
+		}
+		if err := step(doc); err != nil {
+			return nil, fmt.Errorf("upcast v%d->v%d: %w", v, v+1, err)
+		}
+	}
+	doc["schema_version"] = u.current
+	return json.Marshal(doc)
+}
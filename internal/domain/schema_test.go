@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpcasters_Decode(t *testing.T) {
+	u := NewUpcasters(2)
+	u.Register(0, func(map[string]any) error { return nil })
+	// v1 -> v2: поле customer переименовано в customer_id
+	u.Register(1, func(doc map[string]any) error {
+		doc["customer_id"] = doc["customer"]
+		delete(doc, "customer")
+		return nil
+	})
+
+	o, err := u.Decode([]byte(`{"schema_version":1,"order_uid":"u1","customer":"c1"}`), true)
+	require.NoError(t, err)
+	require.Equal(t, 2, o.SchemaVersion)
+	require.Equal(t, "c1", o.CustomerID)
+
+	_, err = u.Decode([]byte(`{"schema_version":3,"order_uid":"u1"}`), false)
+	require.Error(t, err)
+}
+
+func TestUpcasters_StrictUnversioned(t *testing.T) {
+	raw := []byte(`{"order_uid":"u1","unexpected":true}`)
+
+	o, err := DefaultUpcasters.Decode(raw, false)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion, o.SchemaVersion)
+
+	_, err = DefaultUpcasters.Decode(raw, true)
+	require.ErrorContains(t, err, "unexpected")
+
+	// у версионированных сообщений неизвестные поля допустимы
+	_, err = DefaultUpcasters.Decode([]byte(`{"schema_version":1,"order_uid":"u1","unexpected":true}`), true)
+	require.NoError(t, err)
+}
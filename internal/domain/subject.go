@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrErased — данные заказа удалены по запросу субъекта; повторный приём их не восстанавливает.
+	ErrErased = errors.New("order data erased on data subject request")
+	// ErrInvalidSubject — в запросе субъекта нет ни customer_id, ни email.
+	ErrInvalidSubject = errors.New("customer_id or email is required")
+)
+
+// Subject — субъект персональных данных. Заказы ищутся по customer_id или по
+// email доставки; если заданы оба, берутся заказы, подходящие под любое из условий.
+type Subject struct {
+	CustomerID string `json:"customer_id,omitempty"`
+	Email      string `json:"email,omitempty"`
+}
+
+// Normalize обрезает пробелы и приводит email к нижнему регистру.
+func (s Subject) Normalize() Subject {
+	return Subject{CustomerID: strings.TrimSpace(s.CustomerID), Email: strings.ToLower(strings.TrimSpace(s.Email))}
+}
+
+func (s Subject) Validate() error {
+	if s.CustomerID == "" && s.Email == "" {
+		return ErrInvalidSubject
+	}
+	return nil
+}
+
+// Digest — sha256 нормализованного субъекта. Хранится в журнале удалений вместо
+// самих идентификаторов: по нему можно подтвердить, что запрос исполнен.
+func (s Subject) Digest() string {
+	n := s.Normalize()
+	d := sha256.Sum256([]byte("customer_id:" + n.CustomerID + "\nemail:" + n.Email))
+	return hex.EncodeToString(d[:])
+}
+
+// Erasure — запись журнала удалений персональных данных.
+type Erasure struct {
+	ID            int64  `json:"id"`
+	SubjectDigest string `json:"subject_digest"`
+	// Pseudonym заменяет customer_id во всех обезличенных заказах субъекта.
+	Pseudonym   string    `json:"pseudonym"`
+	OrderUIDs   []string  `json:"order_uids"`
+	RequestedBy string    `json:"requested_by"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	ErasedAt    time.Time `json:"erased_at"`
+}
+
+// Anonymize убирает контактные данные покупателя и заменяет customer_id псевдонимом.
+// Город и регион остаются для аналитики, состав и оплата заказа не меняются.
+func (o Order) Anonymize(pseudonym string) Order {
+	o.CustomerID = pseudonym
+	o.Delivery = Delivery{City: o.Delivery.City, Region: o.Delivery.Region}
+	return o
+}
@@ -0,0 +1,99 @@
+// Package fieldcrypt — конвертное шифрование отдельных полей: каждое значение
+// шифруется ключом данных (DEK, свой на запись), а DEK — ключом из Keyring (KEK).
+// При ротации KEK перешифровываются только DEK, сами поля не трогаются.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix помечает зашифрованные значения, чтобы их нельзя было спутать с открытыми.
+const sealedPrefix = "enc:v1:"
+
+var ErrDecrypt = errors.New("decrypt failed")
+
+// NewDEK — случайный ключ данных AES-256.
+func NewDEK() ([]byte, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, fmt.Errorf("dek: %w", err)
+	}
+	return dek, nil
+}
+
+// Seal шифрует значение; aad (обычно "<order_uid>/<поле>") не даёт переставить
+// шифртекст в другую запись или поле. Пустая строка остаётся пустой.
+func Seal(dek []byte, plaintext, aad string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	ct, err := seal(dek, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(ct), nil
+}
+
+// Open расшифровывает значение Seal. Значения без префикса возвращаются как есть:
+// так читаются записи, сохранённые до включения шифрования.
+func Open(dek []byte, s, aad string) (string, error) {
+	enc, ok := strings.CutPrefix(s, sealedPrefix)
+	if !ok {
+		return s, nil
+	}
+	ct, err := base64.RawStdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	pt, err := open(dek, ct, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// IsSealed — значение зашифровано Seal.
+func IsSealed(s string) bool { return strings.HasPrefix(s, sealedPrefix) }
+
+// seal возвращает nonce || ciphertext || tag.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	n := gcm.NonceSize()
+	pt, err := gcm.Open(nil, sealed[:n], sealed[n:], aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return pt, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,135 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyring(t *testing.T, path, active string, ids ...string) {
+	t.Helper()
+	keys := map[string]string{}
+	for _, id := range ids {
+		keys[id] = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id[:1]), KeySize))
+	}
+	data, err := json.Marshal(keyringFile{Active: active, Keys: keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestSealOpen(t *testing.T) {
+	dek, err := NewDEK()
+	require.NoError(t, err)
+
+	s, err := Seal(dek, "+79991234545", "u1/phone")
+	require.NoError(t, err)
+	require.True(t, IsSealed(s))
+	require.NotContains(t, s, "9991234545")
+
+	s2, err := Seal(dek, "+79991234545", "u1/phone")
+	require.NoError(t, err)
+	require.NotEqual(t, s, s2, "nonce must be random")
+
+	pt, err := Open(dek, s, "u1/phone")
+	require.NoError(t, err)
+	require.Equal(t, "+79991234545", pt)
+
+	_, err = Open(dek, s, "u2/phone")
+	require.ErrorIs(t, err, ErrDecrypt)
+	other, _ := NewDEK()
+	_, err = Open(other, s, "u1/phone")
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	empty, err := Seal(dek, "", "u1/email")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+	legacy, err := Open(dek, "plain@mail.ru", "u1/email")
+	require.NoError(t, err)
+	require.Equal(t, "plain@mail.ru", legacy)
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	writeKeyring(t, path, "a1", "a1")
+	kr, err := LoadKeyring(path)
+	require.NoError(t, err)
+
+	dek, _ := NewDEK()
+	kid, wrapped, err := kr.Wrap(dek, "u1")
+	require.NoError(t, err)
+	require.Equal(t, "a1", kid)
+
+	changed, err := kr.Reload()
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	writeKeyring(t, path, "b2", "a1", "b2")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	changed, err = kr.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "b2", kr.Active())
+
+	// старый ключ ещё в keyring: ключ данных распаковывается и переупаковывается новым
+	got, err := kr.Unwrap(kid, wrapped, "u1")
+	require.NoError(t, err)
+	require.Equal(t, dek, got)
+	_, err = kr.Unwrap(kid, wrapped, "u2")
+	require.ErrorIs(t, err, ErrDecrypt)
+	kid, wrapped, err = kr.Wrap(got, "u1")
+	require.NoError(t, err)
+	require.Equal(t, "b2", kid)
+
+	writeKeyring(t, path, "b2", "b2")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	_, err = kr.Reload()
+	require.NoError(t, err)
+	_, err = kr.Unwrap("a1", wrapped, "u1")
+	require.ErrorIs(t, err, ErrUnknownKey)
+	got, err = kr.Unwrap(kid, wrapped, "u1")
+	require.NoError(t, err)
+	require.Equal(t, dek, got)
+
+	writeKeyring(t, path, "c3", "b2")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(3*time.Second)))
+	_, err = kr.Reload()
+	require.ErrorIs(t, err, ErrUnknownKey)
+	require.Equal(t, "b2", kr.Active(), "bad file keeps the previous keys")
+}
+
+func TestKeyring_BlindIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	writeKeyring(t, path, "a1", "a1")
+	kr, err := LoadKeyring(path)
+	require.NoError(t, err)
+	require.False(t, kr.HasIndex())
+	require.Nil(t, kr.BlindIndex("a@b.co"))
+	require.Nil(t, kr.Digest([]byte("a@b.co")))
+
+	index := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("i"), KeySize))
+	data, err := json.Marshal(keyringFile{Active: "a1", Keys: map[string]string{"a1": index}, Index: index})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	_, err = kr.Reload()
+	require.NoError(t, err)
+	require.True(t, kr.HasIndex())
+	require.Equal(t, kr.BlindIndex("a@b.co"), kr.BlindIndex("a@b.co"))
+	require.NotEqual(t, kr.BlindIndex("a@b.co"), kr.BlindIndex("b@b.co"))
+	require.Len(t, kr.Digest([]byte("a@b.co")), 32)
+	require.NotEqual(t, kr.BlindIndex("a@b.co"), kr.Digest([]byte("a@b.co")), "digest and index use separate domains")
+
+	// ключ index менять на ходу нельзя: старые индексы перестали бы находиться
+	other := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("j"), KeySize))
+	data, _ = json.Marshal(keyringFile{Active: "a1", Keys: map[string]string{"a1": index}, Index: other})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	_, err = kr.Reload()
+	require.Error(t, err)
+}
@@ -0,0 +1,179 @@
+package fieldcrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeySize — длина ключа шифрования ключей (KEK), AES-256.
+const KeySize = 32
+
+// ErrUnknownKey — ключ с таким id отсутствует в keyring (например, удалён раньше,
+// чем фоновая перешифровка переупаковала все записи).
+var ErrUnknownKey = errors.New("key not in keyring")
+
+// keyringFile — формат файла:
+//
+//	{"active": "2025-02", "keys": {"2025-01": "<base64 32 байта>", "2025-02": "..."}, "index": "<base64 32 байта>"}
+//
+// Ротация: добавить новый ключ, сделать его active; старый удалять только после того,
+// как фоновая перешифровка закончится (метрика wb_pii_reencrypt_pending = 0).
+// index — ключ слепого индекса для поиска по зашифрованным полям; он не ротируется.
+type keyringFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+	Index  string            `json:"index"`
+}
+
+// Keyring — ключи шифрования ключей из локального файла. Файл перечитывается
+// через Reload, поэтому смена active подхватывается без рестарта.
+type Keyring struct {
+	path string
+
+	mu     sync.RWMutex
+	mod    time.Time
+	active string
+	keys   map[string][]byte
+	index  []byte
+}
+
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{path: path}
+	if _, err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload перечитывает файл, если он изменился. При ошибке остаются прежние ключи.
+func (k *Keyring) Reload() (bool, error) {
+	st, err := os.Stat(k.path)
+	if err != nil {
+		return false, fmt.Errorf("keyring: %w", err)
+	}
+	k.mu.RLock()
+	same := st.ModTime().Equal(k.mod) && k.keys != nil
+	k.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return false, fmt.Errorf("keyring: %w", err)
+	}
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return false, fmt.Errorf("keyring %s: %w", k.path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, enc := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return false, fmt.Errorf("keyring %s: key %q: %w", k.path, id, err)
+		}
+		if len(key) != KeySize {
+			return false, fmt.Errorf("keyring %s: key %q: want %d bytes, got %d", k.path, id, KeySize, len(key))
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[f.Active]; !ok {
+		return false, fmt.Errorf("keyring %s: active key %q: %w", k.path, f.Active, ErrUnknownKey)
+	}
+	var index []byte
+	if f.Index != "" {
+		if index, err = base64.StdEncoding.DecodeString(f.Index); err != nil || len(index) != KeySize {
+			return false, fmt.Errorf("keyring %s: index key must be %d base64-encoded bytes", k.path, KeySize)
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.index != nil && !hmac.Equal(k.index, index) {
+		return false, fmt.Errorf("keyring %s: index key must not change while running", k.path)
+	}
+	changed := k.active != f.Active
+	k.mod, k.active, k.keys, k.index = st.ModTime(), f.Active, keys, index
+	return changed, nil
+}
+
+// Active — id ключа, которым упаковываются новые ключи данных.
+func (k *Keyring) Active() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *Keyring) key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Wrap упаковывает ключ данных активным ключом; aad привязывает результат к записи.
+func (k *Keyring) Wrap(dek []byte, aad string) (kid string, wrapped []byte, err error) {
+	k.mu.RLock()
+	kid, kek := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+	wrapped, err = seal(kek, dek, []byte(kid+"/"+aad))
+	return kid, wrapped, err
+}
+
+// Unwrap распаковывает ключ данных ключом kid.
+func (k *Keyring) Unwrap(kid string, wrapped []byte, aad string) ([]byte, error) {
+	kek, err := k.key(kid)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := open(kek, wrapped, []byte(kid+"/"+aad))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap with %q: %w", kid, err)
+	}
+	return dek, nil
+}
+
+// HasIndex — в keyring есть ключ слепого индекса.
+func (k *Keyring) HasIndex() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.index != nil
+}
+
+// BlindIndex — HMAC-SHA256 значения ключом index: позволяет искать по равенству,
+// не храня значение открытым. Без ключа index — nil.
+func (k *Keyring) BlindIndex(v string) []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.index == nil {
+		return nil
+	}
+	m := hmac.New(sha256.New, k.index)
+	m.Write([]byte(v))
+	return m.Sum(nil)
+}
+
+// Digest — HMAC-SHA256 данных ключом index в отдельном от BlindIndex домене:
+// отпечаток для сравнения версий, по которому нельзя проверить догадку о
+// содержимом без ключа. Без ключа index — nil.
+func (k *Keyring) Digest(data []byte) []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.index == nil {
+		return nil
+	}
+	m := hmac.New(sha256.New, k.index)
+	m.Write([]byte("digest\x00"))
+	m.Write(data)
+	return m.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	IngestMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_ingest_messages_total",
+		Help: "Messages processed by the ingest loop, by result.",
+	}, []string{"result"})
+
+	IngestPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wb_ingest_paused",
+		Help: "1 if ingestion is paused (admin request or open circuit breaker).",
+	})
+
+	DBBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wb_db_breaker_state",
+		Help: "Postgres circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	})
+
+	DBBreakerRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wb_db_breaker_rejected_total",
+		Help: "Repository calls rejected without reaching Postgres because the breaker was open.",
+	})
+)
+
+var (
+	StreamSubscribers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wb_stream_subscribers",
+		Help: "Live order stream subscribers, by transport (sse, ws, grpc).",
+	}, []string{"transport"})
+
+	StreamDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_stream_dropped_total",
+		Help: "Live order stream subscribers disconnected for falling behind, by transport.",
+	}, []string{"transport"})
+)
+
+var (
+	PIIReencrypted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_pii_reencrypted_total",
+		Help: "Orders whose PII was encrypted or whose data key was rewrapped with the active keyring key, by database shard.",
+	}, []string{"shard"})
+
+	PIIReencryptFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wb_pii_reencrypt_failed_total",
+		Help: "Orders skipped by re-encryption because their key is missing or their data cannot be decrypted, by database shard.",
+	}, []string{"shard"})
+
+	PIIReencryptPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wb_pii_reencrypt_pending",
+		Help: "1 while orders under a non-active key or in plaintext may remain; 0 after a pass found none. By database shard.",
+	}, []string{"shard"})
+)
+
+var RetentionMoved = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "wb_retention_moved_total",
+	Help: "Orders past the retention period moved out of the hot tables (to orders_archive or export files), by database shard.",
+}, []string{"shard"})
+
+var ShardUnroutable = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "wb_shard_unroutable_total",
+	Help: "Orders rejected because their shard key maps to no configured shard and there is no default shard.",
+})
+
+var HTTPRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "wb_http_rate_limited_total",
+	Help: "HTTP requests rejected with 429, by route prefix and reason (rate, not_found, unauthorized).",
+}, []string{"route", "reason"})
+
+func Handler() http.Handler { return promhttp.Handler() }
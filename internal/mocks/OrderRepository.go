@@ -77,22 +77,62 @@ func (_m *OrderRepository) LoadAll(limit int) ([]domain.Order, error) {
 	return r0, r1
 }
 
+// Search provides a mock function with given fields: f
+func (_m *OrderRepository) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	ret := _m.Called(f)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Search")
+	}
+
+	var r0 []domain.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(domain.OrderFilter) ([]domain.Order, error)); ok {
+		return rf(f)
+	}
+	if rf, ok := ret.Get(0).(func(domain.OrderFilter) []domain.Order); ok {
+		r0 = rf(f)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(domain.OrderFilter) error); ok {
+		r1 = rf(f)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpsertOrder provides a mock function with given fields: o
-func (_m *OrderRepository) UpsertOrder(o domain.Order) error {
+func (_m *OrderRepository) UpsertOrder(o domain.Order) (domain.UpsertResult, error) {
 	ret := _m.Called(o)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpsertOrder")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.Order) error); ok {
+	var r0 domain.UpsertResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(domain.Order) (domain.UpsertResult, error)); ok {
+		return rf(o)
+	}
+	if rf, ok := ret.Get(0).(func(domain.Order) domain.UpsertResult); ok {
 		r0 = rf(o)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(domain.UpsertResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(domain.Order) error); ok {
+		r1 = rf(o)
+	} else {
+		r1 = ret.Error(1)
 	}
 
-	return r0
+	return r0, r1
 }
 
 // NewOrderRepository creates a new instance of OrderRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
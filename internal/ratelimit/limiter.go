@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Config — ограничения по маршрутам. Для пути берётся правило с самым длинным
+// подходящим префиксом; пути без правила не ограничиваются.
+type Config struct {
+	Routes []Rule
+	// NotFound — корзина промахов (ответов 404 и 401) на клиента и маршрут. Пока она
+	// пуста, маршрут для клиента закрыт целиком, даже если основная корзина полна.
+	// Нулевое значение — выключено.
+	NotFound Limit
+	// IdleTTL — через сколько бездействия корзины клиента забываются.
+	IdleTTL time.Duration
+}
+
+type key struct {
+	route, client string
+	miss          bool
+}
+
+type bucket struct {
+	lim  *rate.Limiter
+	seen time.Time
+}
+
+// Limiter — корзины токенов на пару (маршрут, клиент). Клиента определяет
+// вызывающий: это может быть IP или API-ключ.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[key]*bucket
+	lastSweep time.Time
+}
+
+func New(cfg Config) *Limiter {
+	routes := append([]Rule(nil), cfg.Routes...)
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].Prefix) > len(routes[j].Prefix) })
+	cfg.Routes = routes
+	if cfg.IdleTTL <= 0 {
+		cfg.IdleTTL = 10 * time.Minute
+	}
+	return &Limiter{cfg: cfg, now: time.Now, buckets: map[key]*bucket{}}
+}
+
+// Route — правило для пути; false — путь не ограничивается.
+func (l *Limiter) Route(path string) (Rule, bool) {
+	for _, rule := range l.cfg.Routes {
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule, !rule.Limit.Off()
+		}
+	}
+	return Rule{}, false
+}
+
+// Allow списывает токен клиента на маршруте. При отказе возвращает, через
+// сколько стоит повторить, и причину: "rate" или "not_found".
+func (l *Limiter) Allow(rule Rule, client string) (ok bool, retry time.Duration, reason string) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	if blocked, retry := l.blocked(rule, client, now); blocked {
+		return false, retry, "not_found"
+	}
+	b := l.bucket(key{rule.Prefix, client, false}, rule.Limit, now)
+	res := b.lim.ReserveN(now, 1)
+	if !res.OK() {
+		return false, time.Second, "rate"
+	}
+	if d := res.DelayFrom(now); d > 0 {
+		res.CancelAt(now)
+		return false, d, "rate"
+	}
+	return true, 0, ""
+}
+
+// Blocked — закрыт ли маршрут для клиента опустевшей корзиной промахов и через
+// сколько он откроется. В отличие от Allow основную корзину не расходует.
+func (l *Limiter) Blocked(rule Rule, client string) (bool, time.Duration) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	return l.blocked(rule, client, now)
+}
+
+func (l *Limiter) blocked(rule Rule, client string, now time.Time) (bool, time.Duration) {
+	if l.cfg.NotFound.Off() {
+		return false, 0
+	}
+	b, found := l.buckets[key{rule.Prefix, client, true}]
+	if !found {
+		return false, 0
+	}
+	b.seen = now
+	if tokens := b.lim.TokensAt(now); tokens < 1 {
+		return true, l.cfg.NotFound.wait(1 - tokens)
+	}
+	return false, 0
+}
+
+// Miss учитывает промах клиента на маршруте: ответ 404 или 401.
+func (l *Limiter) Miss(rule Rule, client string) {
+	if l.cfg.NotFound.Off() {
+		return
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket(key{rule.Prefix, client, true}, l.cfg.NotFound, now).lim.AllowN(now, 1)
+}
+
+func (l *Limiter) bucket(k key, lim Limit, now time.Time) *bucket {
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{lim: rate.NewLimiter(lim.Rate, lim.Burst)}
+		l.buckets[k] = b
+	}
+	b.seen = now
+	return b
+}
+
+// sweep раз в IdleTTL удаляет корзины, которыми давно не пользовались:
+// иначе перебор адресов раздувал бы карту без предела.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.IdleTTL {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.seen) >= l.cfg.IdleTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// RetryAfter — значение заголовка Retry-After в целых секундах, не меньше 1.
+func RetryAfter(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoutes(t *testing.T) {
+	rules, err := ParseRoutes(" /order/=5/s:10, /orders=30/m ,/orders/stream=off")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.Equal(t, Limit{Rate: 5, Burst: 10}, rules[0].Limit)
+	require.Equal(t, Limit{Rate: 0.5, Burst: 30}, rules[1].Limit)
+	require.True(t, rules[2].Limit.Off())
+
+	for _, bad := range []string{"order=5/s", "/order/=5", "/order/=5/d", "/order/=-1/s", "/order/=5/s:0", "/a=1/s,/a=2/s"} {
+		_, err := ParseRoutes(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func newTestLimiter(t *testing.T, routes, notFound string) (*Limiter, *time.Time) {
+	t.Helper()
+	rules, err := ParseRoutes(routes)
+	require.NoError(t, err)
+	nf, err := ParseLimit(notFound)
+	require.NoError(t, err)
+	l := New(Config{Routes: rules, NotFound: nf, IdleTTL: time.Hour})
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_RouteLongestPrefix(t *testing.T) {
+	l, _ := newTestLimiter(t, "/orders=1/s,/orders/stream=off,/order/=2/s", "off")
+	r, ok := l.Route("/orders/search")
+	require.True(t, ok)
+	require.Equal(t, "/orders", r.Prefix)
+	_, ok = l.Route("/orders/stream/ws")
+	require.False(t, ok)
+	_, ok = l.Route("/healthz")
+	require.False(t, ok)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, now := newTestLimiter(t, "/order/=1/s:2", "off")
+	rule, _ := l.Route("/order/x")
+
+	for i := 0; i < 2; i++ {
+		ok, _, _ := l.Allow(rule, "ip:1.1.1.1")
+		require.True(t, ok)
+	}
+	ok, retry, reason := l.Allow(rule, "ip:1.1.1.1")
+	require.False(t, ok)
+	require.Equal(t, "rate", reason)
+	require.Equal(t, time.Second, retry)
+
+	// у другого клиента своя корзина
+	ok, _, _ = l.Allow(rule, "ip:2.2.2.2")
+	require.True(t, ok)
+
+	*now = now.Add(time.Second)
+	ok, _, _ = l.Allow(rule, "ip:1.1.1.1")
+	require.True(t, ok)
+}
+
+func TestLimiter_NotFoundClosesRoute(t *testing.T) {
+	l, now := newTestLimiter(t, "/order/=100/s", "1/m:2")
+	rule, _ := l.Route("/order/x")
+
+	for i := 0; i < 2; i++ {
+		ok, _, _ := l.Allow(rule, "key:k")
+		require.True(t, ok)
+		l.Miss(rule, "key:k")
+	}
+	ok, retry, reason := l.Allow(rule, "key:k")
+	require.False(t, ok)
+	require.Equal(t, "not_found", reason)
+	require.Equal(t, time.Minute, retry)
+	require.Equal(t, 60, RetryAfter(retry))
+
+	*now = now.Add(time.Minute)
+	ok, _, _ = l.Allow(rule, "key:k")
+	require.True(t, ok)
+}
+
+func TestLimiter_SweepsIdleClients(t *testing.T) {
+	l, now := newTestLimiter(t, "/order/=1/s", "off")
+	rule, _ := l.Route("/order/x")
+	l.Allow(rule, "a")
+	*now = now.Add(2 * time.Hour)
+	l.Allow(rule, "b")
+	require.Len(t, l.buckets, 1)
+}
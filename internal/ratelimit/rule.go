@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit — скорость пополнения корзины и её ёмкость. Нулевое значение — без ограничения.
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+func (l Limit) Off() bool { return l.Burst == 0 }
+
+// wait — время, за которое накопится n токенов.
+func (l Limit) wait(n float64) time.Duration {
+	if l.Rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration(n / float64(l.Rate) * float64(time.Second))
+}
+
+func (l Limit) String() string {
+	if l.Off() {
+		return "off"
+	}
+	return strconv.FormatFloat(float64(l.Rate), 'g', -1, 64) + "/s:" + strconv.Itoa(l.Burst)
+}
+
+var units = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+// ParseLimit разбирает "N/unit:burst" (unit — s, m или h) или "off".
+// Без ":burst" ёмкость равна N, но не меньше 1.
+//
+//	10/s:20   — 10 запросов в секунду, всплеск до 20
+//	30/m      — 30 в минуту, всплеск до 30
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Limit{}, nil
+	}
+	spec, burstStr, hasBurst := strings.Cut(s, ":")
+	num, unit, ok := strings.Cut(spec, "/")
+	per, known := units[unit]
+	if !ok || !known {
+		return Limit{}, fmt.Errorf("limit %q: want N/s, N/m or N/h", s)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: rate must be a positive number", s)
+	}
+	burst := max(1, int(n))
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("limit %q: burst must be a positive integer", s)
+		}
+	}
+	return Limit{Rate: rate.Limit(n / per.Seconds()), Burst: burst}, nil
+}
+
+// Rule — ограничение для путей с префиксом Prefix.
+type Rule struct {
+	Prefix string
+	Limit  Limit
+}
+
+// ParseRoutes разбирает "prefix=limit,...", например
+// "/order/=5/s:10,/orders=2/s:5,/orders/stream=off".
+func ParseRoutes(s string) ([]Rule, error) {
+	var rules []Rule
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, spec, ok := strings.Cut(part, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("route rule %q: want /prefix=limit", part)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("route rule %q: duplicate prefix", part)
+		}
+		seen[prefix] = true
+		lim, err := ParseLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", prefix, err)
+		}
+		rules = append(rules, Rule{Prefix: prefix, Limit: lim})
+	}
+	return rules, nil
+}
+
+func (c Config) String() string {
+	parts := make([]string, 0, len(c.Routes)+1)
+	for _, r := range c.Routes {
+		parts = append(parts, r.Prefix+"="+r.Limit.String())
+	}
+	return strings.Join(parts, ",") + " not_found=" + c.NotFound.String()
+}
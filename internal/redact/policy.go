@@ -0,0 +1,156 @@
+package redact
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// Mode — что видит роль, которой поле не раскрыто.
+type Mode int
+
+const (
+	Mask Mode = iota
+	Hide
+)
+
+func (m Mode) String() string {
+	if m == Hide {
+		return "hide"
+	}
+	return "mask"
+}
+
+// Rule: роль Reveal и старше видят поле как есть, остальные — по Mode.
+type Rule struct {
+	Reveal auth.Role
+	Mode   Mode
+}
+
+// Поля Delivery, которыми управляет политика (имена — json-теги).
+const (
+	FieldName    = "name"
+	FieldPhone   = "phone"
+	FieldZip     = "zip"
+	FieldCity    = "city"
+	FieldAddress = "address"
+	FieldRegion  = "region"
+	FieldEmail   = "email"
+)
+
+type field struct {
+	get  func(*domain.Delivery) *string
+	mask func(string) string
+}
+
+var fields = map[string]field{
+	FieldName:    {func(d *domain.Delivery) *string { return &d.Name }, domain.MaskName},
+	FieldPhone:   {func(d *domain.Delivery) *string { return &d.Phone }, domain.MaskPhone},
+	FieldZip:     {func(d *domain.Delivery) *string { return &d.Zip }, domain.MaskText},
+	FieldCity:    {func(d *domain.Delivery) *string { return &d.City }, domain.MaskText},
+	FieldAddress: {func(d *domain.Delivery) *string { return &d.Address }, domain.MaskText},
+	FieldRegion:  {func(d *domain.Delivery) *string { return &d.Region }, domain.MaskText},
+	FieldEmail:   {func(d *domain.Delivery) *string { return &d.Email }, domain.MaskEmail},
+}
+
+// Policy задаёт правило для каждого поля Delivery; поля без правила отдаются как есть.
+type Policy struct {
+	rules map[string]Rule
+}
+
+// Default: контакты покупателя целиком видят support и admin, viewer — маску.
+// Город, регион и индекс открыты всем.
+func Default() Policy {
+	return Policy{rules: map[string]Rule{
+		FieldName:    {Reveal: auth.RoleSupport, Mode: Mask},
+		FieldPhone:   {Reveal: auth.RoleSupport, Mode: Mask},
+		FieldAddress: {Reveal: auth.RoleSupport, Mode: Mask},
+		FieldEmail:   {Reveal: auth.RoleSupport, Mode: Mask},
+	}}
+}
+
+// Parse разбирает PII_POLICY: "field=role[:mask|hide],...", например
+// "phone=support:mask,address=admin:hide,zip=viewer"; role none — поле открыто всем. Перечисленные поля
+// переопределяют Default, остальные остаются как в Default. Пустая строка — Default.
+func Parse(spec string) (Policy, error) {
+	p := Default()
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, rule, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok {
+			return Policy{}, fmt.Errorf("pii policy %q: want field=role[:mode]", part)
+		}
+		if _, known := fields[name]; !known {
+			return Policy{}, fmt.Errorf("pii policy: unknown field %q (known: %s)", name, strings.Join(knownFields(), ", "))
+		}
+		roleName, modeName, _ := strings.Cut(rule, ":")
+		role := auth.RoleNone
+		if strings.TrimSpace(roleName) != "none" {
+			var err error
+			if role, err = auth.ParseRole(roleName); err != nil {
+				return Policy{}, fmt.Errorf("pii policy %s: %w", name, err)
+			}
+		}
+		var mode Mode
+		switch strings.ToLower(strings.TrimSpace(modeName)) {
+		case "", "mask":
+			mode = Mask
+		case "hide":
+			mode = Hide
+		default:
+			return Policy{}, fmt.Errorf("pii policy %s: unknown mode %q", name, modeName)
+		}
+		p.rules[name] = Rule{Reveal: role, Mode: mode}
+	}
+	return p, nil
+}
+
+func knownFields() []string {
+	names := make([]string, 0, len(fields))
+	for n := range fields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String — политика в формате Parse, поля по алфавиту.
+func (p Policy) String() string {
+	parts := make([]string, 0, len(p.rules))
+	for _, name := range knownFields() {
+		if r, ok := p.rules[name]; ok {
+			parts = append(parts, name+"="+r.Reveal.String()+":"+r.Mode.String())
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Delivery применяет политику для роли.
+func (p Policy) Delivery(d domain.Delivery, role auth.Role) domain.Delivery {
+	for name, rule := range p.rules {
+		if role >= rule.Reveal {
+			continue
+		}
+		f := fields[name]
+		v := f.get(&d)
+		if rule.Mode == Hide {
+			*v = ""
+		} else {
+			*v = f.mask(*v)
+		}
+	}
+	return d
+}
+
+// Order возвращает копию заказа с Delivery, отредактированной для роли.
+func (p Policy) Order(o domain.Order, role auth.Role) domain.Order {
+	o.Delivery = p.Delivery(o.Delivery, role)
+	return o
+}
@@ -0,0 +1,49 @@
+package redact
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/auth"
+	"github.com/oziev02/wb/internal/domain"
+)
+
+func TestPolicy_Delivery(t *testing.T) {
+	d := domain.Delivery{
+		Name: "Ivan Ivanov", Phone: "+7 (999) 123-45-45", Zip: "420000", City: "Kazan",
+		Address: "Lenina 1", Region: "Tatarstan", Email: "alice@mail.ru",
+	}
+
+	masked := Default().Delivery(d, auth.RoleViewer)
+	require.Equal(t, domain.Delivery{
+		Name: "I*** I***", Phone: "+7***45", Zip: "420000", City: "Kazan",
+		Address: "L***", Region: "Tatarstan", Email: "a***@mail.ru",
+	}, masked)
+	require.Equal(t, d, Default().Delivery(d, auth.RoleSupport))
+
+	p, err := Parse("address=admin:hide, zip=viewer, phone=none")
+	require.NoError(t, err)
+	require.Equal(t, "address=admin:hide,email=support:mask,name=support:mask,phone=none:mask,zip=viewer:mask", p.String())
+	got := p.Delivery(d, auth.RoleSupport)
+	require.Empty(t, got.Address)
+	require.Equal(t, d.Phone, got.Phone)
+	require.Equal(t, d.Email, got.Email)
+	require.Equal(t, "4***", p.Delivery(d, auth.RoleNone).Zip)
+
+	for _, bad := range []string{"phone", "ssn=admin", "phone=root", "phone=admin:blur"} {
+		_, err := Parse(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestDelivery_StringIsMasked(t *testing.T) {
+	o := domain.Order{OrderUID: "u1", Delivery: domain.Delivery{Name: "Ivan", Phone: "89991234545", Email: "a@b.ru", City: "Kazan"}}
+	s := fmt.Sprintf("%v %+v %#v", o, o, o.Delivery)
+	require.NotContains(t, s, "Ivan")
+	require.NotContains(t, s, "1234545")
+	require.NotContains(t, s, "a@b.ru")
+	require.Contains(t, s, "8***45")
+	require.Contains(t, s, "Kazan")
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// WatchFilter — условия подписки на поток заказов; пустые поля не фильтруют.
+type WatchFilter struct {
+	DeliveryService string
+	CustomerID      string
+	Entry           string
+}
+
+func (f WatchFilter) Match(o domain.Order) bool {
+	return (f.DeliveryService == "" || f.DeliveryService == o.DeliveryService) &&
+		(f.CustomerID == "" || f.CustomerID == o.CustomerID) &&
+		(f.Entry == "" || f.Entry == o.Entry)
+}
+
+// Subscription — подписка на Broadcaster. Канал Events закрывается при Close
+// или если подписчик не успевает читать (тогда Dropped() == true).
+type Subscription struct {
+	ch      chan domain.OrderEvent
+	filter  WatchFilter
+	b       *Broadcaster
+	dropped atomic.Bool
+}
+
+func (s *Subscription) Events() <-chan domain.OrderEvent { return s.ch }
+
+func (s *Subscription) Dropped() bool { return s.dropped.Load() }
+
+func (s *Subscription) Close() { s.b.remove(s, false) }
+
+// Broadcaster раздаёт события подписчикам без блокировки публикующего:
+// если буфер подписчика полон, подписчик отключается.
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+func NewBroadcaster(buffer int) *Broadcaster {
+	return &Broadcaster{subs: map[*Subscription]struct{}{}, buffer: buffer}
+}
+
+func (b *Broadcaster) Subscribe(f WatchFilter) *Subscription {
+	s := &Subscription{ch: make(chan domain.OrderEvent, b.buffer), filter: f, b: b}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+func (b *Broadcaster) Publish(ev domain.OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.filter.Match(ev.Order) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			b.removeLocked(s, true)
+		}
+	}
+}
+
+func (b *Broadcaster) remove(s *Subscription, dropped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(s, dropped)
+}
+
+// канал закрывается только здесь и только при удалении из subs — двойного close не будет.
+func (b *Broadcaster) removeLocked(s *Subscription, dropped bool) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	s.dropped.Store(dropped)
+	close(s.ch)
+}
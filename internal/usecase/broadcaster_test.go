@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+func TestBroadcaster_FilterAndDropSlow(t *testing.T) {
+	b := NewBroadcaster(1)
+	cdek := b.Subscribe(WatchFilter{DeliveryService: "cdek"})
+	all := b.Subscribe(WatchFilter{})
+	defer cdek.Close()
+
+	b.Publish(domain.OrderEvent{Order: domain.Order{OrderUID: "u1", DeliveryService: "meest"}})
+	require.Empty(t, cdek.Events())
+	require.Len(t, all.Events(), 1)
+
+	// буфер all полон — следующее событие отключает его, но не cdek
+	b.Publish(domain.OrderEvent{Order: domain.Order{OrderUID: "u2", DeliveryService: "cdek"}})
+	require.Equal(t, "u2", (<-cdek.Events()).Order.OrderUID)
+	require.Equal(t, "u1", (<-all.Events()).Order.OrderUID)
+	_, open := <-all.Events()
+	require.False(t, open)
+	require.True(t, all.Dropped())
+	require.False(t, cdek.Dropped())
+
+	all.Close() // повторное закрытие безопасно
+}
@@ -1,6 +1,11 @@
 package usecase
 
 import (
+	"bytes"
+	"errors"
+	"log"
+	"time"
+
 	"github.com/oziev02/wb/internal/domain"
 )
 
@@ -11,13 +16,54 @@ type OrdersCachePort interface {
 	BulkSet([]domain.Order)
 }
 
+// StaleReader — опциональная возможность кэша отдать недавно вытесненную запись.
+type StaleReader interface {
+	GetStale(id string) (domain.Order, bool)
+}
+
+// CacheStatus — откуда взят заказ; отдаётся клиенту в X-Cache.
+type CacheStatus string
+
+const (
+	CacheHit   CacheStatus = "HIT"
+	CacheMiss  CacheStatus = "MISS"
+	CacheStale CacheStatus = "STALE"
+)
+
+type LookupResult struct {
+	Order domain.Order
+	Found bool
+	Cache CacheStatus
+}
+
 type OrderService struct {
-	repo  domain.OrderRepository
-	cache OrdersCachePort
+	repo     domain.OrderRepository
+	cache    OrdersCachePort
+	events   *Broadcaster
+	subjects SubjectStore
 }
 
-func NewOrderService(r domain.OrderRepository, c OrdersCachePort) *OrderService {
-	return &OrderService{repo: r, cache: c}
+type ServiceOption func(*OrderService)
+
+// WithSubjectStore включает экспорт и удаление данных субъекта.
+func WithSubjectStore(st SubjectStore) ServiceOption {
+	return func(s *OrderService) { s.subjects = st }
+}
+
+// буфер подписчика Watch: столько событий он может отставать, прежде чем будет отключён.
+const watchBuffer = 64
+
+const (
+	DefaultSearchLimit = 100
+	MaxSearchLimit     = 1000
+)
+
+func NewOrderService(r domain.OrderRepository, c OrdersCachePort, opts ...ServiceOption) *OrderService {
+	s := &OrderService{repo: r, cache: c, events: NewBroadcaster(watchBuffer)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *OrderService) InitCache(limit int) error {
@@ -33,21 +79,157 @@ func (s *OrderService) Ingest(o domain.Order) error {
 	if err := o.Validate(); err != nil {
 		return err
 	}
-	if err := s.repo.UpsertOrder(o); err != nil {
+	o.SchemaVersion = domain.CurrentSchemaVersion
+	o.UpdatedAt = time.Now().UTC()
+	res, err := s.repo.UpsertOrder(o)
+	if err != nil {
+		if errors.Is(err, domain.ErrErased) {
+			// повторная доставка заказа, данные которого удалены: подтверждаем и не кэшируем
+			log.Printf("[usecase] skip %s: %v", o.OrderUID, err)
+			return nil
+		}
 		return err
 	}
+	// без изменений в БД не трогаем кэш и не шлём событие: иначе Last-Modified и ETag
+	// сдвигались бы с каждой повторной доставкой, а подписчики получали бы дубли
+	if !res.Changed {
+		return nil
+	}
+	o = withStoredTime(o, res)
 	s.cache.Set(o)
+	s.events.Publish(domain.NewOrderEvent(domain.EventOrderIngested, o, o.UpdatedAt))
 	return nil
 }
 
+// Watch подписывает на принятые заказы. Подписку нужно закрыть.
+func (s *OrderService) Watch(f WatchFilter) *Subscription { return s.events.Subscribe(f) }
+
+// Search ищет заказы в хранилище; Limit приводится к [1, MaxSearchLimit].
+func (s *OrderService) Search(f domain.OrderFilter) ([]domain.Order, error) {
+	switch {
+	case f.Limit <= 0:
+		f.Limit = DefaultSearchLimit
+	case f.Limit > MaxSearchLimit:
+		f.Limit = MaxSearchLimit
+	}
+	return s.repo.Search(f)
+}
+
 func (s *OrderService) Get(id string) (domain.Order, bool, error) {
+	res, err := s.Lookup(id)
+	return res.Order, res.Found, err
+}
+
+// List — последние limit заказов из хранилища, минуя кэш.
+func (s *OrderService) List(limit int) ([]domain.Order, error) {
+	return s.repo.LoadAll(limit)
+}
+
+// Lookup — Get с информацией об источнике. Если БД вернула ошибку, но в кэше
+// осталась вытесненная копия, отдаётся она (stale-while-error).
+func (s *OrderService) Lookup(id string) (LookupResult, error) {
 	if o, ok := s.cache.Get(id); ok {
-		return o, true, nil
+		return LookupResult{Order: o, Found: true, Cache: CacheHit}, nil
 	}
 	o, ok, err := s.repo.GetByID(id)
-	if err != nil || !ok {
-		return domain.Order{}, false, err
+	if err != nil {
+		if sr, can := s.cache.(StaleReader); can {
+			if stale, found := sr.GetStale(id); found {
+				log.Printf("[usecase] serving stale %s: %v", id, err)
+				return LookupResult{Order: stale, Found: true, Cache: CacheStale}, nil
+			}
+		}
+		return LookupResult{Cache: CacheMiss}, err
+	}
+	if !ok {
+		return LookupResult{Cache: CacheMiss}, nil
 	}
 	s.cache.Set(o)
-	return o, true, nil
+	return LookupResult{Order: o, Found: true, Cache: CacheMiss}, nil
+}
+
+// IngestOutcome — результат повторной обработки заказа (replay).
+type IngestOutcome int
+
+const (
+	OutcomeAccepted IngestOutcome = iota
+	OutcomeRejected
+	OutcomeUnchanged
+	// OutcomeFailed — заказ не обработан из-за ошибки хранилища.
+	OutcomeFailed
+)
+
+func (o IngestOutcome) String() string {
+	switch o {
+	case OutcomeAccepted:
+		return "accepted"
+	case OutcomeRejected:
+		return "rejected"
+	case OutcomeUnchanged:
+		return "unchanged"
+	case OutcomeFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Reingest прогоняет заказ через валидацию и сравнивает с сохранённой версией.
+// В dryRun ничего не пишет; иначе изменённые заказы сохраняются как в Ingest.
+// Ошибка валидации возвращается с OutcomeRejected, ошибки хранилища — с OutcomeFailed.
+// Заказ, данные которого удалены по запросу субъекта, считается OutcomeUnchanged.
+func (s *OrderService) Reingest(o domain.Order, dryRun bool) (IngestOutcome, error) {
+	if err := o.Validate(); err != nil {
+		return OutcomeRejected, err
+	}
+	o.SchemaVersion = domain.CurrentSchemaVersion
+	cur, ok, err := s.repo.GetByID(o.OrderUID)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	if ok && sameOrder(cur, o) {
+		return OutcomeUnchanged, nil
+	}
+	if dryRun {
+		// при записи UpsertOrder вернул бы ErrErased — отчёт dry-run должен совпасть
+		if s.subjects != nil {
+			erased, err := s.subjects.IsErased(o.OrderUID)
+			if err != nil {
+				return OutcomeFailed, err
+			}
+			if erased {
+				return OutcomeUnchanged, nil
+			}
+		}
+		return OutcomeAccepted, nil
+	}
+	o.UpdatedAt = time.Now().UTC()
+	res, err := s.repo.UpsertOrder(o)
+	if err != nil {
+		if errors.Is(err, domain.ErrErased) {
+			return OutcomeUnchanged, nil
+		}
+		return OutcomeFailed, err
+	}
+	if !res.Changed {
+		return OutcomeUnchanged, nil
+	}
+	o = withStoredTime(o, res)
+	s.cache.Set(o)
+	s.events.Publish(domain.NewOrderEvent(domain.EventOrderIngested, o, o.UpdatedAt))
+	return OutcomeAccepted, nil
+}
+
+// withStoredTime берёт updated_at из хранилища, если оно его вернуло.
+func withStoredTime(o domain.Order, res domain.UpsertResult) domain.Order {
+	if !res.UpdatedAt.IsZero() {
+		o.UpdatedAt = res.UpdatedAt
+	}
+	return o
+}
+
+func sameOrder(a, b domain.Order) bool {
+	ra, errA := a.RawJSON()
+	rb, errB := b.RawJSON()
+	return errA == nil && errB == nil && bytes.Equal(ra, rb)
 }
@@ -2,23 +2,26 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/oziev02/wb/internal/cache"
 	"github.com/oziev02/wb/internal/domain"
 )
 
 type repoMock struct {
-	upsert func(o domain.Order) error
+	upsert func(o domain.Order) (domain.UpsertResult, error)
 	get    func(id string) (domain.Order, bool, error)
 	load   func(limit int) ([]domain.Order, error)
 }
 
-func (m repoMock) UpsertOrder(o domain.Order) error              { return m.upsert(o) }
-func (m repoMock) GetByID(id string) (domain.Order, bool, error) { return m.get(id) }
-func (m repoMock) LoadAll(limit int) ([]domain.Order, error)     { return m.load(limit) }
+func (m repoMock) UpsertOrder(o domain.Order) (domain.UpsertResult, error) { return m.upsert(o) }
+func (m repoMock) GetByID(id string) (domain.Order, bool, error)           { return m.get(id) }
+func (m repoMock) LoadAll(limit int) ([]domain.Order, error)               { return m.load(limit) }
+func (m repoMock) Search(domain.OrderFilter) ([]domain.Order, error)       { return nil, nil }
 
 type cacheMock struct{ store map[string]domain.Order }
 
@@ -42,7 +45,7 @@ func sample() domain.Order {
 
 func TestIngest_Valid(t *testing.T) {
 	r := repoMock{
-		upsert: func(o domain.Order) error { return nil },
+		upsert: func(o domain.Order) (domain.UpsertResult, error) { return domain.UpsertResult{Changed: true}, nil },
 		load:   func(int) ([]domain.Order, error) { return nil, nil },
 		get:    func(string) (domain.Order, bool, error) { return domain.Order{}, false, nil },
 	}
@@ -57,7 +60,7 @@ func TestIngest_Valid(t *testing.T) {
 func TestGet_FallbackToDB(t *testing.T) {
 	o := sample()
 	r := repoMock{
-		upsert: func(o domain.Order) error { return nil },
+		upsert: func(o domain.Order) (domain.UpsertResult, error) { return domain.UpsertResult{Changed: true}, nil },
 		load:   func(int) ([]domain.Order, error) { return nil, nil },
 		get:    func(string) (domain.Order, bool, error) { return o, true, nil },
 	}
@@ -71,9 +74,11 @@ func TestGet_FallbackToDB(t *testing.T) {
 
 func TestIngest_Invalid(t *testing.T) {
 	r := repoMock{
-		upsert: func(o domain.Order) error { return errors.New("should not be called") },
-		load:   func(int) ([]domain.Order, error) { return nil, nil },
-		get:    func(string) (domain.Order, bool, error) { return domain.Order{}, false, nil },
+		upsert: func(o domain.Order) (domain.UpsertResult, error) {
+			return domain.UpsertResult{}, errors.New("should not be called")
+		},
+		load: func(int) ([]domain.Order, error) { return nil, nil },
+		get:  func(string) (domain.Order, bool, error) { return domain.Order{}, false, nil },
 	}
 	c := &cacheMock{store: map[string]domain.Order{}}
 	s := NewOrderService(r, c)
@@ -82,3 +87,168 @@ func TestIngest_Invalid(t *testing.T) {
 	err := s.Ingest(bad)
 	require.Error(t, err)
 }
+
+func TestReingest_Outcomes(t *testing.T) {
+	stored := sample()
+	stored.SchemaVersion = domain.CurrentSchemaVersion
+	upserts := 0
+	r := repoMock{
+		upsert: func(o domain.Order) (domain.UpsertResult, error) {
+			upserts++
+			return domain.UpsertResult{Changed: true}, nil
+		},
+		load: func(int) ([]domain.Order, error) { return nil, nil },
+		get:  func(string) (domain.Order, bool, error) { return stored, true, nil },
+	}
+	c := &cacheMock{store: map[string]domain.Order{}}
+	s := NewOrderService(r, c)
+
+	out, err := s.Reingest(stored, false)
+	require.NoError(t, err)
+	require.Equal(t, OutcomeUnchanged, out)
+
+	changed := stored
+	changed.TrackNumber = "tn2"
+	out, err = s.Reingest(changed, true)
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, out)
+	require.Zero(t, upserts, "dry-run must not write")
+
+	out, err = s.Reingest(changed, false)
+	require.NoError(t, err)
+	require.Equal(t, OutcomeAccepted, out)
+	require.Equal(t, 1, upserts)
+
+	bad := stored
+	bad.Items = nil
+	out, err = s.Reingest(bad, false)
+	require.Error(t, err)
+	require.Equal(t, OutcomeRejected, out)
+}
+
+func TestReingest_ErasedDryRunMatchesApply(t *testing.T) {
+	upserts := 0
+	r := repoMock{
+		upsert: func(domain.Order) (domain.UpsertResult, error) {
+			upserts++
+			return domain.UpsertResult{}, domain.ErrErased
+		},
+		get: func(string) (domain.Order, bool, error) { return sample().Anonymize("erased-1"), true, nil },
+	}
+	c := &cacheMock{store: map[string]domain.Order{}}
+	s := NewOrderService(r, c, WithSubjectStore(&subjectsMock{erased: map[string]bool{"u1": true}}))
+
+	dry, err := s.Reingest(sample(), true)
+	require.NoError(t, err)
+	require.Zero(t, upserts)
+	applied, err := s.Reingest(sample(), false)
+	require.NoError(t, err)
+	require.Equal(t, OutcomeUnchanged, applied)
+	require.Equal(t, applied, dry, "dry-run reports what apply does")
+}
+
+func TestLookup_StaleOnRepoError(t *testing.T) {
+	o := sample()
+	c := cache.NewOrdersCache(1, time.Hour, cache.WithStaleGrace(time.Hour))
+	defer c.Close()
+	c.Set(o)
+	c.Set(domain.Order{OrderUID: "other"}) // вытесняет u1 в grace
+
+	r := repoMock{get: func(string) (domain.Order, bool, error) { return domain.Order{}, false, domain.ErrUnavailable }}
+	svc := NewOrderService(r, c)
+
+	res, err := svc.Lookup("u1")
+	require.NoError(t, err)
+	require.True(t, res.Found)
+	require.Equal(t, CacheStale, res.Cache)
+	require.Equal(t, o.OrderUID, res.Order.OrderUID)
+
+	_, err = svc.Lookup("missing")
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+}
+
+type subjectsMock struct {
+	got    domain.Erasure
+	found  []domain.Order
+	erased map[string]bool
+	err    error // ошибка части шардов: found всё равно обезличены
+}
+
+func (m *subjectsMock) IsErased(id string) (bool, error) { return m.erased[id], nil }
+
+func (m *subjectsMock) FindBySubject(domain.Subject) ([]domain.Order, error) { return m.found, nil }
+func (m *subjectsMock) EraseSubject(_ domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error) {
+	m.got = e
+	var anon []domain.Order
+	for _, o := range m.found {
+		e.OrderUIDs = append(e.OrderUIDs, o.OrderUID)
+		anon = append(anon, o.Anonymize(e.Pseudonym))
+	}
+	return e, anon, m.err
+}
+
+func TestEraseSubject_ReplacesCachedOrders(t *testing.T) {
+	c := &cacheMock{store: map[string]domain.Order{"u1": sample()}}
+	subjects := &subjectsMock{found: []domain.Order{sample()}}
+	s := NewOrderService(repoMock{}, c, WithSubjectStore(subjects))
+
+	_, err := s.EraseSubject(domain.Subject{}, domain.Erasure{})
+	require.ErrorIs(t, err, domain.ErrInvalidSubject)
+
+	rec, err := s.EraseSubject(domain.Subject{Email: " A@B.co "}, domain.Erasure{RequestedBy: "api_key:ops"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"u1"}, rec.OrderUIDs)
+	require.Equal(t, domain.Subject{Email: "a@b.co"}.Digest(), subjects.got.SubjectDigest)
+	require.Contains(t, rec.Pseudonym, "erased-")
+	require.Equal(t, "api_key:ops", rec.RequestedBy)
+	require.Empty(t, c.store["u1"].Delivery.Email)
+	require.Equal(t, rec.Pseudonym, c.store["u1"].CustomerID)
+}
+
+func TestEraseSubject_PartialFailureStillUpdatesCache(t *testing.T) {
+	c := &cacheMock{store: map[string]domain.Order{"u1": sample()}}
+	subjects := &subjectsMock{found: []domain.Order{sample()}, err: fmt.Errorf("%w: failed shards: s1", domain.ErrUnavailable)}
+	s := NewOrderService(repoMock{}, c, WithSubjectStore(subjects))
+
+	_, err := s.EraseSubject(domain.Subject{CustomerID: "c1"}, domain.Erasure{})
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+	require.Empty(t, c.store["u1"].Delivery.Email, "orders erased on healthy shards are not served from cache")
+}
+
+func TestIngest_ErasedIsSkipped(t *testing.T) {
+	r := repoMock{upsert: func(domain.Order) (domain.UpsertResult, error) { return domain.UpsertResult{}, domain.ErrErased }}
+	c := &cacheMock{store: map[string]domain.Order{}}
+	s := NewOrderService(r, c)
+	require.NoError(t, s.Ingest(sample()))
+	require.Empty(t, c.store)
+}
+
+func TestIngest_UnchangedKeepsCachedVersion(t *testing.T) {
+	stored := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	changed := true
+	r := repoMock{
+		upsert: func(domain.Order) (domain.UpsertResult, error) {
+			return domain.UpsertResult{Changed: changed, UpdatedAt: stored}, nil
+		},
+		get: func(string) (domain.Order, bool, error) { return domain.Order{}, false, nil },
+	}
+	c := &cacheMock{store: map[string]domain.Order{}}
+	s := NewOrderService(r, c)
+
+	require.NoError(t, s.Ingest(sample()))
+	require.Equal(t, stored, c.store["u1"].UpdatedAt, "cache carries updated_at from the repository")
+
+	// повторная доставка без изменений не перезаписывает кэш и не публикуется
+	changed = false
+	sub := s.Watch(WatchFilter{})
+	defer sub.Close()
+	c.store["u1"] = domain.Order{OrderUID: "u1", UpdatedAt: stored, TrackNumber: "cached"}
+	require.NoError(t, s.Ingest(sample()))
+	require.Equal(t, "cached", c.store["u1"].TrackNumber)
+	require.Empty(t, sub.Events())
+
+	// чтение перед записью не нашло заказ, но его успела записать параллельная доставка
+	out, err := s.Reingest(sample(), false)
+	require.NoError(t, err)
+	require.Equal(t, OutcomeUnchanged, out)
+}
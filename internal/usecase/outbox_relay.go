@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+type OutboxStore interface {
+	RelayPending(ctx context.Context, limit int,
+		publish func(context.Context, []domain.OutboxRecord) error) (int, error)
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, recs []domain.OutboxRecord) error
+}
+
+// OutboxRelay периодически переносит события из outbox в брокер.
+type OutboxRelay struct {
+	store    OutboxStore
+	pub      EventPublisher
+	batch    int
+	interval time.Duration
+}
+
+func NewOutboxRelay(s OutboxStore, p EventPublisher, batch int, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{store: s, pub: p, batch: batch, interval: interval}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		n, err := r.store.RelayPending(ctx, r.batch, r.pub.Publish)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[outbox] relay: %v", err)
+		}
+		// полный батч — скорее всего есть ещё, не ждём тика
+		if err == nil && n == r.batch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// memOutbox ведёт себя как OutboxRepo.RelayPending: события помечаются
+// отправленными только после успешной публикации.
+type memOutbox struct {
+	mu      sync.Mutex
+	pending []domain.OutboxRecord
+	calls   int
+	err     error
+}
+
+func (s *memOutbox) RelayPending(ctx context.Context, limit int,
+	publish func(context.Context, []domain.OutboxRecord) error,
+) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+	batch := s.pending[:min(limit, len(s.pending))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	s.pending = s.pending[len(batch):]
+	return len(batch), nil
+}
+
+func (s *memOutbox) state() (calls, pending int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, len(s.pending)
+}
+
+type pubFunc func(ctx context.Context, recs []domain.OutboxRecord) error
+
+func (f pubFunc) Publish(ctx context.Context, recs []domain.OutboxRecord) error { return f(ctx, recs) }
+
+func records(n int) []domain.OutboxRecord {
+	out := make([]domain.OutboxRecord, n)
+	for i := range out {
+		out[i] = domain.OutboxRecord{ID: int64(i + 1), Type: domain.EventOrderCreated}
+	}
+	return out
+}
+
+func runRelay(t *testing.T, r *OutboxRelay) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	return func() {
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+	}
+}
+
+func TestOutboxRelay_PublishesFullBatchesWithoutWaiting(t *testing.T) {
+	store := &memOutbox{pending: records(5)}
+	var (
+		mu   sync.Mutex
+		sent []int64
+	)
+	pub := pubFunc(func(_ context.Context, recs []domain.OutboxRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rec := range recs {
+			sent = append(sent, rec.ID)
+		}
+		return nil
+	})
+	stop := runRelay(t, NewOutboxRelay(store, pub, 2, time.Hour))
+	defer stop()
+
+	// 2+2+1 без ожидания тика; неполный батч ждёт следующего
+	require.Eventually(t, func() bool { calls, _ := store.state(); return calls == 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	calls, pending := store.state()
+	require.Equal(t, 3, calls)
+	require.Zero(t, pending)
+	mu.Lock()
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, sent)
+	mu.Unlock()
+}
+
+func TestOutboxRelay_PublishFailureKeepsEventsAndWaitsForTick(t *testing.T) {
+	store := &memOutbox{pending: records(2)}
+	var (
+		mu   sync.Mutex
+		fail = true
+	)
+	pub := pubFunc(func(context.Context, []domain.OutboxRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("broker down")
+		}
+		return nil
+	})
+	stop := runRelay(t, NewOutboxRelay(store, pub, 2, 100*time.Millisecond))
+	defer stop()
+
+	// ошибка не крутит цикл: следующая попытка — только по тику
+	time.Sleep(50 * time.Millisecond)
+	calls, pending := store.state()
+	require.Equal(t, 1, calls)
+	require.Equal(t, 2, pending, "failed batch stays in the outbox")
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	require.Eventually(t, func() bool { _, pending := store.state(); return pending == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestOutboxRelay_StoreErrorDoesNotSpin(t *testing.T) {
+	// ошибка БД — тоже ожидание тика, а не немедленный повтор
+	store := &memOutbox{err: errors.New("db down")}
+	stop := runRelay(t, NewOutboxRelay(store, pubFunc(func(context.Context, []domain.OutboxRecord) error { return nil }), 2, time.Hour))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	calls, _ := store.state()
+	require.Equal(t, 1, calls)
+}
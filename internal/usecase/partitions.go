@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type PartitionStore interface {
+	EnsurePartitions(ctx context.Context, now time.Time, ahead int) (int, error)
+	DetachBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// PartitionMaintainer в фоне держит помесячные секции orders: создаёт их на ahead
+// месяцев вперёд и, если detachAfter > 0, отсоединяет секции старше detachAfter месяцев.
+// Первый проход — сразу при запуске, чтобы новые заказы не попадали в default-секцию.
+type PartitionMaintainer struct {
+	store       PartitionStore
+	ahead       int
+	detachAfter int
+	interval    time.Duration
+	now         func() time.Time
+}
+
+func NewPartitionMaintainer(s PartitionStore, ahead, detachAfter int, interval time.Duration) *PartitionMaintainer {
+	return &PartitionMaintainer{store: s, ahead: ahead, detachAfter: detachAfter, interval: interval, now: time.Now}
+}
+
+func (m *PartitionMaintainer) Run(ctx context.Context) error {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		m.pass(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (m *PartitionMaintainer) pass(ctx context.Context) {
+	now := m.now().UTC()
+	if n, err := m.store.EnsurePartitions(ctx, now, m.ahead); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[partitions] create: %v", err)
+		}
+	} else if n > 0 {
+		log.Printf("[partitions] created %d month(s) ahead", n)
+	}
+	if m.detachAfter <= 0 {
+		return
+	}
+	before := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -m.detachAfter, 0)
+	detached, err := m.store.DetachBefore(ctx, before)
+	if len(detached) > 0 {
+		log.Printf("[partitions] detached %v", detached)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[partitions] detach: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type partitionsMock struct {
+	ahead  int
+	before time.Time
+}
+
+func (m *partitionsMock) EnsurePartitions(_ context.Context, _ time.Time, ahead int) (int, error) {
+	m.ahead = ahead
+	return 0, nil
+}
+
+func (m *partitionsMock) DetachBefore(_ context.Context, before time.Time) ([]string, error) {
+	m.before = before
+	return nil, nil
+}
+
+func TestPartitionMaintainer_DetachCutoff(t *testing.T) {
+	s := &partitionsMock{}
+	m := NewPartitionMaintainer(s, 3, 12, time.Hour)
+	m.now = func() time.Time { return time.Date(2025, 3, 17, 23, 0, 0, 0, time.FixedZone("MSK", 3*3600)) }
+	m.pass(context.Background())
+	require.Equal(t, 3, s.ahead)
+	require.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), s.before)
+
+	s = &partitionsMock{}
+	NewPartitionMaintainer(s, 3, 0, time.Hour).pass(context.Background())
+	require.True(t, s.before.IsZero(), "detach is off by default")
+}
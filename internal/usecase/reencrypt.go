@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+type ReencryptStore interface {
+	// ReencryptBatch возвращает, сколько заказов приведено к активному ключу и
+	// сколько пропущено, потому что их перешифровать нельзя.
+	ReencryptBatch(ctx context.Context, limit int) (done, failed int, err error)
+}
+
+// Reencryptor в фоне приводит сохранённые заказы к активному ключу шифрования:
+// после ротации ключа и после включения шифрования на базе с открытыми данными.
+type Reencryptor struct {
+	store    ReencryptStore
+	shard    string
+	batch    int
+	interval time.Duration
+}
+
+func NewReencryptor(s ReencryptStore, shard string, batch int, interval time.Duration) *Reencryptor {
+	return &Reencryptor{store: s, shard: shard, batch: batch, interval: interval}
+}
+
+func (r *Reencryptor) Run(ctx context.Context) error {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		n, failed, err := r.store.ReencryptBatch(ctx, r.batch)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("[pii] reencrypt %s: %v", r.shard, err)
+		case err == nil:
+			metrics.PIIReencrypted.WithLabelValues(r.shard).Add(float64(n))
+			if failed > 0 {
+				metrics.PIIReencryptFailed.WithLabelValues(r.shard).Add(float64(failed))
+				log.Printf("[pii] reencrypt %s: %d orders skipped, see pii_failed_kek", r.shard, failed)
+			}
+			if n+failed < r.batch {
+				metrics.PIIReencryptPending.WithLabelValues(r.shard).Set(0)
+			} else {
+				metrics.PIIReencryptPending.WithLabelValues(r.shard).Set(1)
+				continue // полный батч — скорее всего есть ещё, не ждём тика
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+// memReencrypt выдаёт заказы батчами; bad из них перешифровать нельзя, и
+// они, как pii_failed_kek в БД, больше не выбираются.
+type memReencrypt struct {
+	mu      sync.Mutex
+	pending int
+	bad     int
+	calls   int
+	err     error
+}
+
+func (s *memReencrypt) ReencryptBatch(_ context.Context, limit int) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	failed := min(limit, s.bad)
+	done := min(limit-failed, s.pending)
+	s.bad -= failed
+	s.pending -= done
+	return done, failed, nil
+}
+
+func (s *memReencrypt) state() (calls, left int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, s.pending + s.bad
+}
+
+func runReencryptor(t *testing.T, r *Reencryptor) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	return func() {
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+	}
+}
+
+func TestReencryptor_DrainsFullBatchesAndSkipsBadRows(t *testing.T) {
+	store := &memReencrypt{pending: 3, bad: 2}
+	reencrypted := testutil.ToFloat64(metrics.PIIReencrypted.WithLabelValues("s1"))
+	failed := testutil.ToFloat64(metrics.PIIReencryptFailed.WithLabelValues("s1"))
+
+	stop := runReencryptor(t, NewReencryptor(store, "s1", 2, time.Hour))
+	defer stop()
+
+	// батч из одних плохих заказов — тоже полный: проход идёт дальше без тика
+	require.Eventually(t, func() bool { calls, _ := store.state(); return calls == 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	calls, left := store.state()
+	require.Equal(t, 3, calls)
+	require.Zero(t, left)
+	require.Equal(t, 3.0, testutil.ToFloat64(metrics.PIIReencrypted.WithLabelValues("s1"))-reencrypted)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.PIIReencryptFailed.WithLabelValues("s1"))-failed)
+	require.Zero(t, testutil.ToFloat64(metrics.PIIReencryptPending.WithLabelValues("s1")))
+}
+
+func TestReencryptor_ErrorWaitsForTick(t *testing.T) {
+	store := &memReencrypt{err: errors.New("db down")}
+	stop := runReencryptor(t, NewReencryptor(store, "s2", 2, time.Hour))
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	calls, _ := store.state()
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+type RetentionStore interface {
+	// MoveBatch убирает из рабочих таблиц до limit заказов, созданных раньше before.
+	MoveBatch(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// Retention в фоне переносит заказы старше срока хранения из рабочих таблиц
+// (в архив или в файлы — решает RetentionStore), чтобы orders не росла без предела.
+// Кэш не трогается: перенесённые заказы вытесняются из него по TTL.
+type Retention struct {
+	store    RetentionStore
+	shard    string
+	keep     time.Duration
+	batch    int
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewRetention(s RetentionStore, shard string, keep time.Duration, batch int, interval time.Duration) *Retention {
+	return &Retention{store: s, shard: shard, keep: keep, batch: batch, interval: interval, now: time.Now}
+}
+
+func (r *Retention) Run(ctx context.Context) error {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		n, err := r.store.MoveBatch(ctx, r.now().Add(-r.keep), r.batch)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("[retention] move %s: %v", r.shard, err)
+		case err == nil:
+			metrics.RetentionMoved.WithLabelValues(r.shard).Add(float64(n))
+			if n == r.batch {
+				continue // полный батч — скорее всего есть ещё, не ждём тика
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oziev02/wb/internal/metrics"
+)
+
+type moveFunc func(ctx context.Context, before time.Time, limit int) (int, error)
+
+func (f moveFunc) MoveBatch(ctx context.Context, before time.Time, limit int) (int, error) {
+	return f(ctx, before, limit)
+}
+
+func TestRetention_DrainsFullBatchesWithoutWaiting(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	left := 5
+	var cutoffs []time.Time
+	r := NewRetention(moveFunc(func(_ context.Context, before time.Time, limit int) (int, error) {
+		cutoffs = append(cutoffs, before)
+		n := min(left, limit)
+		left -= n
+		if n < limit {
+			cancel()
+		}
+		return n, nil
+	}), "s1", 30*24*time.Hour, 2, time.Hour)
+	r.now = func() time.Time { return now }
+	moved := testutil.ToFloat64(metrics.RetentionMoved.WithLabelValues("s1"))
+
+	require.ErrorIs(t, r.Run(ctx), context.Canceled)
+	require.Equal(t, 5.0, testutil.ToFloat64(metrics.RetentionMoved.WithLabelValues("s1"))-moved)
+	require.Len(t, cutoffs, 3, "2+2+1: a partial batch waits for the next tick")
+	require.Equal(t, now.AddDate(0, 0, -30), cutoffs[0])
+}
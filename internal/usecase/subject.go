@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/oziev02/wb/internal/domain"
+)
+
+// SubjectStore — операции хранилища для запросов субъектов персональных данных.
+type SubjectStore interface {
+	FindBySubject(s domain.Subject) ([]domain.Order, error)
+	EraseSubject(s domain.Subject, e domain.Erasure) (domain.Erasure, []domain.Order, error)
+	// IsErased — заказ обезличен по запросу субъекта (в рабочих таблицах или в архиве),
+	// и повторный приём его не перезапишет.
+	IsErased(orderUID string) (bool, error)
+}
+
+var errNoSubjectStore = errors.New("data subject requests are not supported by the configured storage")
+
+// SubjectExport — выгрузка всех заказов субъекта.
+type SubjectExport struct {
+	Subject     domain.Subject `json:"subject"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Orders      []domain.Order `json:"orders"`
+}
+
+// ExportSubject собирает все заказы субъекта из хранилища, минуя кэш.
+func (s *OrderService) ExportSubject(sub domain.Subject) (SubjectExport, error) {
+	sub = sub.Normalize()
+	if err := sub.Validate(); err != nil {
+		return SubjectExport{}, err
+	}
+	if s.subjects == nil {
+		return SubjectExport{}, errNoSubjectStore
+	}
+	orders, err := s.subjects.FindBySubject(sub)
+	if err != nil {
+		return SubjectExport{}, err
+	}
+	if orders == nil {
+		orders = []domain.Order{}
+	}
+	return SubjectExport{Subject: sub, GeneratedAt: time.Now().UTC(), Orders: orders}, nil
+}
+
+// EraseSubject обезличивает все заказы субъекта и обновляет кэш (включая
+// stale-копии), чтобы старые данные не отдавались до истечения TTL.
+// В req заполняются RequestedBy, RequestID и Reason; остальное проставляет сервис.
+// Сообщения в топиках брокера не трогаются — они исчезают по retention топика.
+func (s *OrderService) EraseSubject(sub domain.Subject, req domain.Erasure) (domain.Erasure, error) {
+	sub = sub.Normalize()
+	if err := sub.Validate(); err != nil {
+		return domain.Erasure{}, err
+	}
+	if s.subjects == nil {
+		return domain.Erasure{}, errNoSubjectStore
+	}
+	pseudonym, err := newPseudonym()
+	if err != nil {
+		return domain.Erasure{}, err
+	}
+	req.SubjectDigest = sub.Digest()
+	req.Pseudonym = pseudonym
+	req.ErasedAt = time.Now().UTC()
+	rec, orders, err := s.subjects.EraseSubject(sub, req)
+	// при частичной ошибке (часть шардов) уже обезличенные заказы тоже уходят в кэш
+	for _, o := range orders {
+		s.cache.Set(o)
+	}
+	if err != nil {
+		return domain.Erasure{}, err
+	}
+	return rec, nil
+}
+
+// newPseudonym — случайный, а не производный от customer_id: обратно не восстанавливается.
+func newPseudonym() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "erased-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_date_created;
+DROP INDEX IF EXISTS idx_orders_track_number;
+DROP INDEX IF EXISTS idx_orders_delivery_service_created;
+DROP INDEX IF EXISTS idx_orders_customer_created;
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_orders_customer_created ON orders(customer_id, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service_created ON orders(delivery_service, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created DESC);
@@ -0,0 +1,5 @@
+-- зашифрованные значения остаются в deliveries и raw_json: перед откатом их нужно расшифровать
+DROP INDEX IF EXISTS idx_orders_pii_kek;
+ALTER TABLE orders DROP COLUMN IF EXISTS raw_digest;
+ALTER TABLE orders DROP COLUMN IF EXISTS pii_dek;
+ALTER TABLE orders DROP COLUMN IF EXISTS pii_kek;
@@ -0,0 +1,6 @@
+-- конвертное шифрование контактных полей: ключ данных заказа, упакованный ключом pii_kek из keyring
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pii_kek TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pii_dek BYTEA;
+-- sha256 открытого raw_json: по нему upsert отличает повторную доставку от изменения
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS raw_digest BYTEA;
+CREATE INDEX IF NOT EXISTS idx_orders_pii_kek ON orders(pii_kek);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS erasures;
+DROP INDEX IF EXISTS idx_deliveries_email_lower;
+DROP INDEX IF EXISTS idx_deliveries_email_idx;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS email_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS erased_at;
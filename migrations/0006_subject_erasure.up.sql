@@ -0,0 +1,20 @@
+-- обезличенные заказы: повторный приём из брокера их не перезаписывает
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
+
+-- слепой индекс email (HMAC ключом index из keyring) для поиска по зашифрованным доставкам
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS email_idx BYTEA;
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_idx ON deliveries(email_idx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_lower ON deliveries(lower(email));
+
+-- журнал удалений: идентификаторы субъекта хранятся только в виде sha256
+CREATE TABLE IF NOT EXISTS erasures (
+    id BIGSERIAL PRIMARY KEY,
+    subject_digest TEXT NOT NULL,
+    pseudonym TEXT NOT NULL,
+    order_uids TEXT[] NOT NULL,
+    requested_by TEXT NOT NULL,
+    request_id TEXT,
+    reason TEXT,
+    erased_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_erasures_subject_digest ON erasures(subject_digest);
@@ -0,0 +1,2 @@
+-- заказы из архива при откате теряются: перед откатом их нужно вернуть в orders
+DROP TABLE IF EXISTS orders_archive;
@@ -0,0 +1,18 @@
+-- архив заказов старше срока хранения. Заказ целиком лежит в raw_json, поэтому
+-- дочерние таблицы не копируются; контакты остаются зашифрованными тем же ключом.
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_uid TEXT PRIMARY KEY,
+    customer_id TEXT,
+    date_created TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    raw_json JSONB NOT NULL,
+    raw_digest BYTEA,
+    pii_kek TEXT,
+    pii_dek BYTEA,
+    email_idx BYTEA,
+    erased_at TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_orders_archive_customer ON orders_archive(customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_archive_email_idx ON orders_archive(email_idx);
+CREATE INDEX IF NOT EXISTS idx_orders_archive_pii_kek ON orders_archive(pii_kek);
@@ -0,0 +1,76 @@
+-- обратно в обычные таблицы; отсоединённые секции (<таблица>_pYYYY_MM вне orders)
+-- не возвращаются — их данные нужно перенести заранее
+CREATE TABLE orders_unpartitioned (
+    order_uid TEXT PRIMARY KEY,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT,
+    internal_signature TEXT,
+    customer_id TEXT,
+    delivery_service TEXT,
+    shardkey TEXT,
+    sm_id INT,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT,
+    raw_json JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    pii_kek TEXT,
+    pii_dek BYTEA,
+    raw_digest BYTEA,
+    erased_at TIMESTAMPTZ
+);
+CREATE TABLE deliveries_unpartitioned (
+    order_uid TEXT PRIMARY KEY REFERENCES orders_unpartitioned(order_uid) ON DELETE CASCADE,
+    name TEXT, phone TEXT, zip TEXT, city TEXT, address TEXT, region TEXT, email TEXT,
+    email_idx BYTEA
+);
+CREATE TABLE payments_unpartitioned (
+    order_uid TEXT PRIMARY KEY REFERENCES orders_unpartitioned(order_uid) ON DELETE CASCADE,
+    transaction TEXT, request_id TEXT, currency TEXT, provider TEXT,
+    amount INT, payment_dt BIGINT, bank TEXT, delivery_cost INT, goods_total INT, custom_fee INT
+);
+CREATE TABLE items_unpartitioned (
+    id BIGINT PRIMARY KEY DEFAULT nextval('items_id_seq'),
+    order_uid TEXT REFERENCES orders_unpartitioned(order_uid) ON DELETE CASCADE,
+    chrt_id INT, track_number TEXT, price INT, rid TEXT,
+    name TEXT, sale INT, size TEXT, total_price INT, nm_id INT, brand TEXT, status INT
+);
+
+INSERT INTO orders_unpartitioned
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, raw_json, updated_at, pii_kek, pii_dek, raw_digest, erased_at
+FROM orders;
+INSERT INTO deliveries_unpartitioned
+SELECT order_uid, name, phone, zip, city, address, region, email, email_idx FROM deliveries;
+INSERT INTO payments_unpartitioned
+SELECT order_uid, transaction, request_id, currency, provider,
+       amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+FROM payments;
+INSERT INTO items_unpartitioned
+SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+FROM items;
+ALTER SEQUENCE items_id_seq OWNED BY items_unpartitioned.id;
+
+DROP TABLE order_keys;
+DROP TABLE items;
+DROP TABLE payments;
+DROP TABLE deliveries;
+DROP TABLE orders;
+
+ALTER TABLE orders_unpartitioned RENAME TO orders;
+ALTER TABLE deliveries_unpartitioned RENAME TO deliveries;
+ALTER TABLE payments_unpartitioned RENAME TO payments;
+ALTER TABLE items_unpartitioned RENAME TO items;
+ALTER INDEX orders_unpartitioned_pkey RENAME TO orders_pkey;
+ALTER INDEX deliveries_unpartitioned_pkey RENAME TO deliveries_pkey;
+ALTER INDEX payments_unpartitioned_pkey RENAME TO payments_pkey;
+ALTER INDEX items_unpartitioned_pkey RENAME TO items_pkey;
+
+CREATE INDEX idx_items_order_uid ON items(order_uid);
+CREATE INDEX idx_orders_customer_created ON orders(customer_id, date_created DESC);
+CREATE INDEX idx_orders_delivery_service_created ON orders(delivery_service, date_created DESC);
+CREATE INDEX idx_orders_track_number ON orders(track_number);
+CREATE INDEX idx_orders_date_created ON orders(date_created DESC);
+CREATE INDEX idx_orders_pii_kek ON orders(pii_kek);
+CREATE INDEX idx_deliveries_email_idx ON deliveries(email_idx);
+CREATE INDEX idx_deliveries_email_lower ON deliveries(lower(email));
@@ -0,0 +1,145 @@
+-- orders и дочерние таблицы секционируются по date_created помесячно (UTC).
+-- Ключ секционирования входит во все первичные ключи и внешние ключи, поэтому
+-- date_created появляется и в deliveries, payments, items. Данные копируются:
+-- на большой базе миграцию нужно запускать в окно обслуживания.
+--
+-- Секции называются <таблица>_pYYYY_MM; их заранее создаёт и отсоединяет
+-- фоновое обслуживание (postgres.Partitioner). Строки вне созданных секций
+-- попадают в <таблица>_pdefault.
+
+ALTER TABLE items RENAME TO items_unpartitioned;
+ALTER TABLE payments RENAME TO payments_unpartitioned;
+ALTER TABLE deliveries RENAME TO deliveries_unpartitioned;
+ALTER TABLE orders RENAME TO orders_unpartitioned;
+ALTER INDEX orders_pkey RENAME TO orders_unpartitioned_pkey;
+ALTER INDEX deliveries_pkey RENAME TO deliveries_unpartitioned_pkey;
+ALTER INDEX payments_pkey RENAME TO payments_unpartitioned_pkey;
+ALTER INDEX items_pkey RENAME TO items_unpartitioned_pkey;
+DROP INDEX IF EXISTS idx_items_order_uid;
+DROP INDEX IF EXISTS idx_orders_customer_created;
+DROP INDEX IF EXISTS idx_orders_delivery_service_created;
+DROP INDEX IF EXISTS idx_orders_track_number;
+DROP INDEX IF EXISTS idx_orders_date_created;
+DROP INDEX IF EXISTS idx_orders_pii_kek;
+DROP INDEX IF EXISTS idx_deliveries_email_idx;
+DROP INDEX IF EXISTS idx_deliveries_email_lower;
+
+CREATE TABLE orders (
+    order_uid TEXT NOT NULL,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT,
+    internal_signature TEXT,
+    customer_id TEXT,
+    delivery_service TEXT,
+    shardkey TEXT,
+    sm_id INT,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT,
+    raw_json JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    pii_kek TEXT,
+    pii_dek BYTEA,
+    raw_digest BYTEA,
+    erased_at TIMESTAMPTZ,
+    PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE deliveries (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    name TEXT, phone TEXT, zip TEXT, city TEXT, address TEXT, region TEXT, email TEXT,
+    email_idx BYTEA,
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT deliveries_order_fk FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created) ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payments (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    transaction TEXT, request_id TEXT, currency TEXT, provider TEXT,
+    amount INT, payment_dt BIGINT, bank TEXT, delivery_cost INT, goods_total INT, custom_fee INT,
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT payments_order_fk FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created) ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items (
+    id BIGINT NOT NULL DEFAULT nextval('items_id_seq'),
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    chrt_id INT, track_number TEXT, price INT, rid TEXT,
+    name TEXT, sale INT, size TEXT, total_price INT, nm_id INT, brand TEXT, status INT,
+    PRIMARY KEY (id, date_created),
+    CONSTRAINT items_order_fk FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created) ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+ALTER SEQUENCE items_id_seq OWNED BY items.id;
+
+CREATE INDEX idx_orders_customer_created ON orders(customer_id, date_created DESC);
+CREATE INDEX idx_orders_delivery_service_created ON orders(delivery_service, date_created DESC);
+CREATE INDEX idx_orders_track_number ON orders(track_number);
+CREATE INDEX idx_orders_date_created ON orders(date_created DESC);
+CREATE INDEX idx_orders_pii_kek ON orders(pii_kek);
+CREATE INDEX idx_items_order_uid ON items(order_uid, date_created);
+CREATE INDEX idx_deliveries_email_idx ON deliveries(email_idx);
+CREATE INDEX idx_deliveries_email_lower ON deliveries(lower(email));
+
+-- order_uid -> date_created: по нему GetByID и upsert попадают в одну секцию,
+-- а не проверяют индекс каждой.
+CREATE TABLE order_keys (
+    order_uid TEXT PRIMARY KEY,
+    date_created TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE orders_pdefault PARTITION OF orders DEFAULT;
+CREATE TABLE deliveries_pdefault PARTITION OF deliveries DEFAULT;
+CREATE TABLE payments_pdefault PARTITION OF payments DEFAULT;
+CREATE TABLE items_pdefault PARTITION OF items DEFAULT;
+
+-- секции от месяца самого старого заказа до трёх месяцев вперёд
+DO $$
+DECLARE
+    m    timestamptz := date_trunc('month', coalesce((SELECT min(date_created) FROM orders_unpartitioned), now()), 'UTC');
+    stop timestamptz := date_trunc('month', now(), 'UTC') + interval '4 months';
+    t    text;
+BEGIN
+    WHILE m < stop LOOP
+        FOREACH t IN ARRAY ARRAY['orders', 'deliveries', 'payments', 'items'] LOOP
+            EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                t || '_p' || to_char(m AT TIME ZONE 'UTC', 'YYYY_MM'), t, m, m + interval '1 month');
+        END LOOP;
+        m := m + interval '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+                    shardkey, sm_id, date_created, oof_shard, raw_json, updated_at, pii_kek, pii_dek, raw_digest, erased_at)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+       shardkey, sm_id, date_created, oof_shard, raw_json, updated_at, pii_kek, pii_dek, raw_digest, erased_at
+FROM orders_unpartitioned;
+
+INSERT INTO order_keys (order_uid, date_created)
+SELECT order_uid, date_created FROM orders_unpartitioned;
+
+INSERT INTO deliveries (order_uid, date_created, name, phone, zip, city, address, region, email, email_idx)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.email_idx
+FROM deliveries_unpartitioned d JOIN orders_unpartitioned o ON o.order_uid = d.order_uid;
+
+INSERT INTO payments (order_uid, date_created, transaction, request_id, currency, provider,
+                      amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+SELECT p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider,
+       p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM payments_unpartitioned p JOIN orders_unpartitioned o ON o.order_uid = p.order_uid;
+
+INSERT INTO items (id, order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size,
+                   total_price, nm_id, brand, status)
+SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+       i.total_price, i.nm_id, i.brand, i.status
+FROM items_unpartitioned i JOIN orders_unpartitioned o ON o.order_uid = i.order_uid;
+
+DROP TABLE items_unpartitioned;
+DROP TABLE payments_unpartitioned;
+DROP TABLE deliveries_unpartitioned;
+DROP TABLE orders_unpartitioned;
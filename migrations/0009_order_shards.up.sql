@@ -0,0 +1,7 @@
+-- справочник шардов: в какой базе лежит заказ. Нужен только в основной базе (DB_URL)
+-- при DB_SHARDS; в базах шардов таблица остаётся пустой.
+CREATE TABLE IF NOT EXISTS order_shards (
+    order_uid TEXT PRIMARY KEY,
+    shard TEXT NOT NULL,
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS pii_failed_kek;
+ALTER TABLE orders DROP COLUMN IF EXISTS pii_failed_kek;
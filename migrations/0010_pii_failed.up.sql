@@ -0,0 +1,10 @@
+-- заказ, который не удалось перешифровать (ключ удалён, шифртекст повреждён),
+-- помечается активным на тот момент ключом и пропускается до смены active
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pii_failed_kek TEXT;
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS pii_failed_kek TEXT;
+
+-- raw_digest теперь HMAC ключом index: старые отпечатки (SHA-256 открытого
+-- raw_json) позволяют подбирать содержимое. Каждый заказ перезапишется один
+-- раз при следующей доставке.
+UPDATE orders SET raw_digest = NULL WHERE raw_digest IS NOT NULL;
+UPDATE orders_archive SET raw_digest = NULL WHERE raw_digest IS NOT NULL;
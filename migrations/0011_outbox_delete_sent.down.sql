@@ -0,0 +1,2 @@
+-- удалённые события не восстанавливаются
+SELECT 1;
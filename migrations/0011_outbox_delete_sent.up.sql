@@ -0,0 +1,3 @@
+-- релей удаляет отправленные события в той же транзакции; накопленные до этого
+-- строки с sent_at больше не нужны
+DELETE FROM outbox WHERE sent_at IS NOT NULL;
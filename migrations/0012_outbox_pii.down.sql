@@ -0,0 +1,2 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS pii_dek;
+ALTER TABLE outbox DROP COLUMN IF EXISTS pii_kek;
@@ -0,0 +1,5 @@
+-- контакты в заказе события шифруются так же, как в orders.raw_json;
+-- релей расшифровывает их перед публикацией. События, записанные раньше,
+-- остаются открытыми, пока релей их не отправит и не удалит.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS pii_kek TEXT;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS pii_dek BYTEA;